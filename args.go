@@ -0,0 +1,45 @@
+package shred
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Helpers for the arguments of builders, which are tokens for terminals and the values built
+// for non-terminals. Their errors wrap ErrResultType and, when returned by a builder,
+// are reported at the position of the rule.
+
+// AsToken returns an argument that is a token or an error if it isn't one.
+func AsToken(arg interface{}) (Token, error) {
+	tok, ok := arg.(Token)
+	if !ok {
+		return nil, newError(ErrResultType, fmt.Sprintf("argument of type %T is not a token", arg))
+	}
+	return tok, nil
+}
+
+// MustText returns the text of an argument that is a token and panics if it isn't one.
+func MustText(arg interface{}) string {
+	tok, err := AsToken(arg)
+	if err != nil {
+		panic("shred: MustText: " + err.Error())
+	}
+	return tok.Text()
+}
+
+// IntValue returns the value of an argument that is an integer token.
+// The token is parsed as a Go integer literal, so it may have a base prefix and underscores.
+func IntValue(arg interface{}) (int64, error) {
+	tok, err := AsToken(arg)
+	if err != nil {
+		return 0, err
+	}
+	if !tok.IsInt() {
+		return 0, newError(ErrResultType, "expected an integer, got "+describeToken(tok))
+	}
+	n, err := strconv.ParseInt(tok.Text(), 0, 64)
+	if err != nil {
+		return 0, newError(ErrResultType, "invalid integer "+tok.Text()+": "+err.(*strconv.NumError).Err.Error())
+	}
+	return n, nil
+}
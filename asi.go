@@ -0,0 +1,66 @@
+package shred
+
+// Semicolons are the rules of automatic semicolon insertion, which turns line breaks into
+// semicolons so that grammars of languages with statements terminated by line breaks don't have to
+// handle them: a semicolon is inserted after the last token of a line if the token is an identifier
+// and Idents is set, a literal and Literals is set, or an operator, punctuation or keyword with
+// a text in After. The last line of the input is treated alike. Preprocess is a Preprocessor
+// inserting tokens with the text of Semicolon, or ";" if it's empty, at the ends of the lines.
+type Semicolons struct {
+	Idents    bool
+	Literals  bool
+	After     []string
+	Semicolon string
+}
+
+// GoSemicolons are the rules of semicolon insertion of Go.
+var GoSemicolons = &Semicolons{
+	Idents:   true,
+	Literals: true,
+	After:    []string{"break", "continue", "fallthrough", "return", "++", "--", ")", "]", "}"},
+}
+
+// Preprocess inserts semicolons at the ends of lines. Lines are told apart by the positions
+// of the tokens, so whitespace tokens aren't needed. Trivia are skipped.
+func (sr *Semicolons) Preprocess(tokens []Token) ([]Token, error) {
+	text := sr.Semicolon
+	if text == "" {
+		text = ";"
+	}
+	after := make(map[string]bool, len(sr.After))
+	for _, s := range sr.After {
+		after[s] = true
+	}
+	ret := make([]Token, 0, len(tokens)+len(tokens)/8)
+	last := -1 // index of the last token in ret that isn't trivia
+	for _, tok := range tokens {
+		if IsTrivia(tok) {
+			ret = append(ret, tok)
+			continue
+		}
+		if last >= 0 && sr.terminates(ret[last], after) {
+			if end := End(ret[last]); tok.IsEOF() || end.Line < tok.Line() {
+				// the semicolon goes right after the token, before the trivia following it
+				ret = append(ret, nil)
+				copy(ret[last+2:], ret[last+1:])
+				ret[last+1] = NewToken(KindOther, text, end)
+			}
+		}
+		last = len(ret)
+		ret = append(ret, tok)
+	}
+	return ret, nil
+}
+
+// terminates reports whether a semicolon is inserted after a token at the end of a line.
+func (sr *Semicolons) terminates(tok Token, after map[string]bool) bool {
+	switch tok.Kind() {
+	case KindIdent:
+		return sr.Idents || after[tok.Text()]
+	case KindInt, KindFloat, KindString, KindRawString, KindChar:
+		return sr.Literals
+	case KindKeyword, KindOther:
+		return after[tok.Text()]
+	}
+	return false
+}
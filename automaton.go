@@ -0,0 +1,256 @@
+package shred
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Mode selects how Build decides on which lookahead terminals rules are reduced.
+type Mode byte
+
+const (
+	// ModeLR0 reduces completed rules on any terminal unless it can be shifted.
+	ModeLR0 Mode = iota
+	// ModeSLR reduces completed rules only on the terminals that can follow their left-hand sides
+	// (SLR(1)). Such grammars have fewer conflicts and syntax errors are detected earlier.
+	ModeSLR
+	// ModeLR1 computes the lookaheads of every item (minimal LR(1)). States with the same items
+	// are merged as in LALR(1) unless their lookaheads are incompatible, i.e. merging them could
+	// cause a reduce/reduce conflict (Pager's weak compatibility). Only the states that need it
+	// are split, so LR(1) grammars are accepted with about as many states as LALR(1) would have.
+	ModeLR1
+)
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeSLR:
+		return "slr"
+	case ModeLR1:
+		return "lr1"
+	}
+	return "lr0"
+}
+
+// automaton is the LR automaton of a grammar with its parse tables. It's built from a copy
+// of the grammar's rules and never modified afterwards, so parses can share it.
+type automaton struct {
+	rules        []*Rule
+	mode         Mode
+	canon        map[string][]*state // states by their items during Build, several ones in ModeLR1
+	queue        []*state            // states whose successors haven't been computed yet
+	nonterminals map[NonTerminal]struct{}
+	terminals    map[Terminal]struct{}
+	initState    *state
+	states       []*state
+	conflicts    []Conflict
+	shiftReduce  int
+	pending      []int32           // operator rules completed in the states or -1, nil without operators
+	reuse        map[string][]item // closures of kernels taken over from the previous automaton by AddRules
+	tables
+	sets
+}
+
+// newAutomaton builds the automaton of a sequence of rules computing states with a number of workers.
+// If the automaton of a prefix of the rules is given, the closures of its states are reused.
+func newAutomaton(rules []*Rule, mode Mode, workers int, prev *automaton) *automaton {
+	if workers < 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	a := &automaton{rules: append([]*Rule(nil), rules...), mode: mode, canon: make(map[string][]*state)}
+	a.intern()
+	if prev != nil {
+		a.reuse = prev.closures(rules)
+	}
+	if mode != ModeLR0 {
+		a.computeSets()
+	}
+	s := &state{rule: -1}
+	for _, r := range a.rulesWithLhs("0") {
+		s.items = append(s.items, item{r, 0})
+		if mode == ModeLR1 {
+			la := newTermSet(len(a.terms))
+			la.add(a.eofID)
+			s.la = append(s.la, la)
+		}
+	}
+	a.closeState(s)
+	a.initState = s
+	a.addState(s)
+	a.expandStates(workers)
+	a.numberStates()
+	if mode == ModeLR1 {
+		// States whose lookaheads grew were expanded again, so reductions are set once at the end.
+		for _, s := range a.states {
+			a.reduceLookahead(s)
+		}
+	}
+	a.buildTables()
+	a.indexOperators()
+	a.canon, a.reuse = nil, nil
+	return a
+}
+
+// numberStates numbers the states reachable from the initial state. The initial state is 0,
+// the others are numbered in the order of their items. States left unreachable because
+// of splits in ModeLR1 are dropped.
+func (a *automaton) numberStates() {
+	a.states = []*state{a.initState}
+	seen := map[*state]bool{a.initState: true}
+	for i := 0; i < len(a.states); i++ {
+		for _, s := range a.states[i].succ {
+			if !seen[s] {
+				seen[s] = true
+				a.states = append(a.states, s)
+			}
+		}
+	}
+	rest := a.states[1:]
+	sort.Slice(rest, func(i, j int) bool { return rest[i].compare(rest[j]) < 0 })
+	for i, s := range a.states {
+		s.id = i
+	}
+}
+
+// stateActions returns the actions of a state sorted by terminal.
+func (a *automaton) stateActions(s *state) ([]Terminal, map[Terminal]action) {
+	var ts []Terminal
+	as := make(map[Terminal]action)
+	for i := range a.terms {
+		switch act := a.actionAt(s.id, i); {
+		case act > 0:
+			as[a.terms[i]] = shift{a.states[act-1]}
+		case act < 0:
+			as[a.terms[i]] = reduce{a.rules[-act-1]}
+		default:
+			continue
+		}
+		ts = append(ts, a.terms[i])
+	}
+	return ts, as
+}
+
+// stateGotos returns the gotos of a state sorted by non-terminal.
+func (a *automaton) stateGotos(s *state) ([]NonTerminal, map[NonTerminal]*state) {
+	var nts []NonTerminal
+	gt := make(map[NonTerminal]*state)
+	for i := range a.nts {
+		if g := a.gotoAt(s.id, i); g > 0 {
+			nt := NonTerminal{a.nts[i]}
+			nts = append(nts, nt)
+			gt[nt] = a.states[g-1]
+		}
+	}
+	return nts, gt
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\l`).Replace(s) + `"`
+}
+
+// WriteDOT writes the automaton of a built grammar in the Graphviz DOT format.
+// States are labelled with their items and reductions, shifts are solid edges and gotos dashed ones.
+func (gr *Grammar) WriteDOT(w io.Writer) error {
+	a, err := gr.built()
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString("digraph automaton {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, s := range a.states {
+		label := fmt.Sprintf("%d\n", s.id)
+		for _, it := range s.items {
+			label += a.itemAsString(it) + "\n"
+		}
+		for _, r := range a.reductions(s) {
+			label += "reduce " + r.String() + "\n"
+		}
+		fmt.Fprintf(&sb, "\ts%d [label=%s];\n", s.id, dotQuote(label))
+	}
+	for _, s := range a.states {
+		ts, as := a.stateActions(s)
+		for _, t := range ts {
+			if sh, ok := as[t].(shift); ok {
+				fmt.Fprintf(&sb, "\ts%d -> s%d [label=%s];\n", s.id, sh.state.id, dotQuote(t.String()))
+			}
+		}
+		nts, gt := a.stateGotos(s)
+		for _, nt := range nts {
+			fmt.Fprintf(&sb, "\ts%d -> s%d [label=%s, style=dashed];\n", s.id, gt[nt].id, dotQuote(nt.Name))
+		}
+	}
+	sb.WriteString("}\n")
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// ruleNumbers returns the numbers of the automaton's rules, the first one of rules listed twice.
+func (a *automaton) ruleNumbers() map[*Rule]int {
+	nums := make(map[*Rule]int, len(a.rules))
+	for i := len(a.rules) - 1; i >= 0; i-- {
+		nums[a.rules[i]] = i
+	}
+	return nums
+}
+
+// isKernel reports whether an item is in the kernel of a state,
+// i.e. it has been advanced or it's an item of the start symbol.
+func (a *automaton) isKernel(it item) bool {
+	return it.dot > 0 || a.rules[it.rule].Lhs == "0"
+}
+
+// WriteReport writes a human-readable description of the automaton of a built grammar
+// listing the rules, conflicts and the kernel items, actions and gotos of every state.
+func (gr *Grammar) WriteReport(w io.Writer) error {
+	a, err := gr.built()
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString("rules\n\n")
+	for i, r := range a.rules {
+		fmt.Fprintf(&sb, "\t%d %s\n", i, r)
+	}
+	if len(a.conflicts) > 0 {
+		sb.WriteString("\nconflicts\n\n")
+		for _, c := range a.conflicts {
+			fmt.Fprintf(&sb, "\t%s\n", c)
+		}
+	}
+	for _, s := range a.states {
+		fmt.Fprintf(&sb, "\nstate %d\n\n", s.id)
+		for _, it := range s.items {
+			if a.isKernel(it) {
+				fmt.Fprintf(&sb, "\t%s\n", a.itemAsString(it))
+			}
+		}
+		sb.WriteByte('\n')
+		ts, as := a.stateActions(s)
+		var def *Rule
+		if d := a.defaults[s.id]; d < 0 {
+			def = a.rules[-d-1]
+		}
+		for _, t := range ts {
+			switch act := as[t].(type) {
+			case shift:
+				fmt.Fprintf(&sb, "\t%s shift %d\n", t, act.state.id)
+			case reduce:
+				if act.rule != def {
+					fmt.Fprintf(&sb, "\t%s reduce %s\n", t, act.rule)
+				}
+			}
+		}
+		nts, gt := a.stateGotos(s)
+		for _, nt := range nts {
+			fmt.Fprintf(&sb, "\t%s goto %d\n", nt.Name, gt[nt].id)
+		}
+		if def != nil {
+			fmt.Fprintf(&sb, "\tdefault reduce %s\n", def)
+		}
+	}
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
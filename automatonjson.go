@@ -0,0 +1,135 @@
+package shred
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// AutomatonJSON is the JSON form of the automaton of a built grammar written by WriteJSON.
+// Terminals, non-terminals, rules and states are referred to by their indices.
+// Terminals are named as in rules: matches are quoted, the identifier terminal is _ident_
+// and EOF is _eof_. The start symbol is 0 and parsing ends when a rule for it is reduced.
+type AutomatonJSON struct {
+	Terminals    []string       `json:"terminals"`
+	NonTerminals []string       `json:"nonterminals"`
+	Rules        []RuleJSON     `json:"rules"`
+	States       []StateJSON    `json:"states"` // the initial state first
+	Conflicts    []ConflictJSON `json:"conflicts"`
+}
+
+// RuleJSON is a rule. Its right-hand side has terminal and non-terminal names.
+type RuleJSON struct {
+	Lhs  string   `json:"lhs"`
+	Rhs  []string `json:"rhs"`
+	Text string   `json:"text"` // the rule as printed
+}
+
+// StateJSON is a state with its items and transitions.
+type StateJSON struct {
+	Items   []ItemJSON   `json:"items"`
+	Actions []ActionJSON `json:"actions"` // by terminal, terminals without actions are errors
+	Gotos   []GotoJSON   `json:"gotos"`   // by non-terminal
+	// Default is the rule reduced for terminals without other actions, if any.
+	// Its reductions are also listed among the actions.
+	Default *int `json:"default,omitempty"`
+}
+
+// ItemJSON is a rule with the position of the dot.
+type ItemJSON struct {
+	Rule int    `json:"rule"`
+	Dot  int    `json:"dot"`
+	Text string `json:"text"` // the item as printed
+}
+
+// ActionJSON is a shift to a state or a reduction of a rule on a terminal.
+type ActionJSON struct {
+	Terminal int  `json:"terminal"`
+	Shift    *int `json:"shift,omitempty"`
+	Reduce   *int `json:"reduce,omitempty"`
+}
+
+// GotoJSON is the state reached over a non-terminal after a reduction.
+type GotoJSON struct {
+	NonTerminal int `json:"nonterminal"`
+	State       int `json:"state"`
+}
+
+// ConflictJSON is a state in which more than one rule can be reduced.
+type ConflictJSON struct {
+	State int   `json:"state"`
+	Rules []int `json:"rules"`
+}
+
+// AutomatonJSON returns the automaton of a built grammar in its JSON form.
+func (gr *Grammar) AutomatonJSON() (*AutomatonJSON, error) {
+	a, err := gr.built()
+	if err != nil {
+		return nil, err
+	}
+	ret := &AutomatonJSON{
+		Terminals:    make([]string, len(a.terms)),
+		NonTerminals: a.nts,
+		Rules:        make([]RuleJSON, len(a.rules)),
+		States:       make([]StateJSON, len(a.states)),
+		Conflicts:    []ConflictJSON{},
+	}
+	for i, t := range a.terms {
+		ret.Terminals[i] = t.String()
+	}
+	for i, r := range a.rules {
+		rhs := make([]string, len(r.Rhs))
+		for j, s := range r.Rhs {
+			rhs[j] = s.String()
+		}
+		ret.Rules[i] = RuleJSON{r.Lhs, rhs, r.String()}
+	}
+	states := make(map[string]int, len(a.states))
+	for _, s := range a.states {
+		states[a.stateAsString(s)] = s.id
+		st := StateJSON{Items: make([]ItemJSON, len(s.items)), Actions: []ActionJSON{}, Gotos: []GotoJSON{}}
+		for i, it := range s.items {
+			st.Items[i] = ItemJSON{it.rule, it.dot, a.itemAsString(it)}
+		}
+		for t := range a.terms {
+			switch act := int(a.actionAt(s.id, t)); {
+			case act > 0:
+				next := act - 1
+				st.Actions = append(st.Actions, ActionJSON{Terminal: t, Shift: &next})
+			case act < 0:
+				rule := -act - 1
+				st.Actions = append(st.Actions, ActionJSON{Terminal: t, Reduce: &rule})
+			}
+		}
+		if d := int(a.defaults[s.id]); d < 0 {
+			rule := -d - 1
+			st.Default = &rule
+		}
+		for nt := range a.nts {
+			if g := int(a.gotoAt(s.id, nt)); g > 0 {
+				st.Gotos = append(st.Gotos, GotoJSON{nt, g - 1})
+			}
+		}
+		ret.States[s.id] = st
+	}
+	nums := a.ruleNumbers()
+	for _, c := range a.conflicts {
+		cj := ConflictJSON{State: states[c.State]}
+		for _, r := range c.Rules {
+			cj.Rules = append(cj.Rules, nums[r])
+		}
+		ret.Conflicts = append(ret.Conflicts, cj)
+	}
+	return ret, nil
+}
+
+// WriteJSON writes the automaton of a built grammar as an indented AutomatonJSON
+// for tools in other languages.
+func (gr *Grammar) WriteJSON(w io.Writer) error {
+	aj, err := gr.AutomatonJSON()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(aj)
+}
@@ -0,0 +1,60 @@
+// Package builders has ready-made builders for the common cases of rules: passing a value
+// through, producing a constant, taking the text of a token and building lists.
+// They're the Builder funcs of shred rules:
+//
+//	{Lhs: "Elements", Rhs: shred.Seq(shred.NT("Value")), Builder: builders.WrapSlice},
+//	{Lhs: "Elements", Rhs: shred.Seq(shred.NT("Elements"), shred.T(","), shred.NT("Value")), Builder: builders.Append(0, 2)},
+//	{Lhs: "Array", Rhs: shred.Seq(shred.T("["), shred.NT("Elements"), shred.T("]")), Builder: builders.PickNth(1)},
+//
+// The argument slices passed to builders are reused by the parser, so the builders copy them
+// into the slices they return.
+package builders
+
+import "github.com/phomola/shred"
+
+// PickNth returns a builder returning the value of the i-th symbol of the right-hand side,
+// counting from 0.
+func PickNth(i int) func([]interface{}) interface{} {
+	return func(args []interface{}) interface{} { return args[i] }
+}
+
+// First returns the value of the first symbol of the right-hand side.
+func First(args []interface{}) interface{} { return args[0] }
+
+// Const returns a builder returning a constant.
+func Const(v interface{}) func([]interface{}) interface{} {
+	return func([]interface{}) interface{} { return v }
+}
+
+// TokenText returns the text of the token of the first symbol of the right-hand side,
+// which must be a terminal.
+func TokenText(args []interface{}) interface{} { return shred.MustText(args[0]) }
+
+// Slice returns the values of the right-hand side as a slice.
+func Slice(args []interface{}) interface{} { return append([]interface{}(nil), args...) }
+
+// WrapSlice returns a slice with the value of the first symbol of the right-hand side.
+// It starts lists continued by Append.
+func WrapSlice(args []interface{}) interface{} { return []interface{}{args[0]} }
+
+// Append returns a builder appending the value of the symbol at elem to the slice
+// of the symbol at list.
+func Append(list, elem int) func([]interface{}) interface{} {
+	return func(args []interface{}) interface{} {
+		return append(args[list].([]interface{}), args[elem])
+	}
+}
+
+// Flatten returns the values of the right-hand side as a slice in which the elements
+// of values that are slices of type []interface{} take the places of the slices.
+func Flatten(args []interface{}) interface{} {
+	var ret []interface{}
+	for _, arg := range args {
+		if l, ok := arg.([]interface{}); ok {
+			ret = append(ret, l...)
+		} else {
+			ret = append(ret, arg)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,92 @@
+package shred
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Catalog holds message templates keyed by code that override the wording of parse errors,
+// for instance to translate them. Templates use the text/template syntax and are executed with MessageData.
+// The function join joins a list in the form "a, b or c" using the catalog's translation of "or".
+type Catalog struct {
+	templates map[Code]*template.Template
+	terms     map[string]string
+}
+
+// MessageData is the data passed to message templates.
+type MessageData struct {
+	Code     Code
+	Pos      Position
+	Text     string   // text of the offending token
+	Kind     string   // kind of the offending token, such as "ident" or "eof"
+	Expected []string // descriptions of the expected terminals, such as "an identifier" or "'+'"
+	Message  string   // the default message
+}
+
+// NewCatalog creates a catalog from templates keyed by code. Terms translate the words
+// used in the data: descriptions of terminals, names of kinds and the word "or".
+func NewCatalog(templates map[Code]string, terms map[string]string) (*Catalog, error) {
+	c := &Catalog{make(map[Code]*template.Template), terms}
+	funcs := template.FuncMap{"join": c.join}
+	codes := make([]string, 0, len(templates))
+	for code := range templates {
+		codes = append(codes, string(code))
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		t, err := template.New(code).Funcs(funcs).Parse(templates[Code(code)])
+		if err != nil {
+			return nil, err
+		}
+		c.templates[Code(code)] = t
+	}
+	return c, nil
+}
+
+func (c *Catalog) term(s string) string {
+	if t, ok := c.terms[s]; ok {
+		return t
+	}
+	return s
+}
+
+func (c *Catalog) join(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + c.term("or") + " " + items[len(items)-1]
+}
+
+// Message returns the message of an error, including its position, using the catalog's template for its code.
+// Errors without a template are rendered by their Error method.
+func (c *Catalog) Message(err error) string {
+	var perr *ParseError
+	if c == nil || !errors.As(err, &perr) {
+		return err.Error()
+	}
+	code := perr.Code()
+	t, ok := c.templates[code]
+	if !ok {
+		return err.Error()
+	}
+	data := MessageData{Code: code, Pos: perr.Pos, Message: perr.Err.Error()}
+	if perr.Token != nil {
+		data.Text = perr.Token.Text()
+		data.Kind = c.term(perr.Token.Kind().String())
+	}
+	for _, e := range perr.Expected {
+		data.Expected = append(data.Expected, c.term(e))
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return err.Error()
+	}
+	return perr.Pos.String() + ": " + sb.String()
+}
+
+// Render renders an error like RenderError using the catalog's templates.
+func (c *Catalog) Render(src string, err error) string {
+	return render(src, err, c)
+}
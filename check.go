@@ -0,0 +1,96 @@
+package shred
+
+import "fmt"
+
+// Warning is a suspicious construct in a grammar.
+type Warning struct {
+	Code Code
+	Rule *Rule // rule the warning is about or nil
+	Msg  string
+}
+
+// String returns the warning message followed by its code.
+func (w Warning) String() string { return w.Msg + " [" + string(w.Code) + "]" }
+
+// Check returns warnings about constructs that are valid but probably mistakes:
+// match terminals the default tokeniser never produces, rules that differ only in their builders
+// and, if the grammar is built, identifier terminals shadowed by matches of keywords.
+// Nil rules and rules with nil symbols are reported with CodeNil and not checked further.
+func (gr *Grammar) Check() []Warning {
+	var ws []Warning
+	var rules []*Rule
+	for i, r := range gr.Rules {
+		if r == nil {
+			ws = append(ws, Warning{CodeNil, nil, fmt.Sprintf("rule %d is nil", i)})
+			continue
+		}
+		ok := true
+		for j, s := range r.Rhs {
+			if s == nil {
+				ws = append(ws, Warning{CodeNil, r, fmt.Sprintf("symbol %d of rule %d is nil", j, i)})
+				ok = false
+			}
+		}
+		if ok {
+			rules = append(rules, r)
+		}
+	}
+	ws = append(ws, gr.ruleWarnings(rules)...)
+	a, _ := gr.current()
+	if a == nil {
+		return ws
+	}
+	for _, s := range a.states {
+		ts, as := a.stateActions(s)
+		if _, ok := as[Ident{}].(shift); !ok {
+			continue
+		}
+		for _, t := range ts {
+			if m, ok := t.(Match); ok && isIdentText(m.Text) {
+				if _, ok := as[t].(shift); ok {
+					ws = append(ws, Warning{CodeShadowedIdent, nil, fmt.Sprintf("identifier %s is not accepted as %s in state %d", m, Ident{}, s.id)})
+				}
+			}
+		}
+	}
+	return ws
+}
+
+// ruleWarnings returns the warnings about rules that don't need the automaton.
+func (gr *Grammar) ruleWarnings(rules []*Rule) []Warning {
+	var ws []Warning
+	seen := make(map[string]*Rule)
+	for _, r := range rules {
+		for _, s := range r.Rhs {
+			if m, ok := s.(Match); ok && !producible(m.Text) {
+				ws = append(ws, Warning{CodeUnproducible, r, fmt.Sprintf("%s in rule %s is never produced by the default tokeniser", m, r)})
+			}
+		}
+		key := r.String()
+		if r2, ok := seen[key]; ok {
+			ws = append(ws, Warning{CodeDuplicateRule, r, fmt.Sprintf("rule %s is a duplicate of rule %d", r, gr.ruleIndex(r2))})
+		} else {
+			seen[key] = r
+		}
+	}
+	return ws
+}
+
+// producible reports whether the default tokeniser produces a token that matches the text.
+func producible(text string) bool {
+	toks := TokeniseString(text)
+	if len(toks) != 2 {
+		return false
+	}
+	k := toks[0].Kind()
+	return (k == KindIdent || k == KindOther) && toks[0].Text() == text
+}
+
+func (gr *Grammar) ruleIndex(r *Rule) int {
+	for i, r2 := range gr.Rules {
+		if r2 == r {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,206 @@
+// Command shred is a tool for developing grammars. It reads a grammar in the textual format
+// of ParseRules or a YAML spec read by ReadSpec, reports its conflicts and warnings, optionally prints the automaton and
+// parses input files with it, printing their trees.
+//
+// Usage:
+//
+//	shred [flags] grammar [input ...]
+//	shred [flags] -grammar grammar [input ...]
+//
+// With -o, it writes a standalone parser generated by Grammar.WriteGo, so grammars can be
+// compiled by go generate:
+//
+//	//go:generate shred -grammar lang.shred -o parser_gen.go -pkg mylang
+//
+// The package defaults to $GOPACKAGE, which go generate sets. Nothing is written if the grammar
+// has errors. With -tree-sitter and -yacc, the grammar is exported as a tree-sitter grammar.js
+// and a goyacc grammar of the package. The exit status is 1 if the grammar has errors or an input doesn't parse.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/phomola/shred"
+)
+
+var (
+	mode   = flag.String("mode", "", "construction of the automaton: lr0 (default), slr or lr1")
+	report = flag.Bool("report", false, "print the states of the automaton")
+	dot    = flag.Bool("dot", false, "print the automaton in the DOT language")
+	js     = flag.Bool("json", false, "print the automaton as JSON")
+	stats  = flag.Bool("stats", false, "print statistics of the automaton")
+	color  = flag.Bool("color", false, "colour diagnostics")
+	input  = flag.String("e", "", "parse the given input")
+	file   = flag.String("grammar", "", "grammar file, instead of the first argument")
+	output = flag.String("o", "", "write a generated parser to the file")
+	pkg    = flag.String("pkg", os.Getenv("GOPACKAGE"), "package of the generated parser")
+	ts     = flag.String("tree-sitter", "", "write the grammar as a tree-sitter grammar.js to the file")
+	yacc   = flag.String("yacc", "", "write the grammar as a goyacc grammar to the file")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: shred [flags] grammar [input ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	grammarFile, inputs := *file, flag.Args()
+	if grammarFile == "" {
+		if len(inputs) == 0 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		grammarFile, inputs = inputs[0], inputs[1:]
+	}
+	if !run(os.Stdout, os.Stderr, grammarFile, inputs) {
+		os.Exit(1)
+	}
+}
+
+// run builds the grammar and parses the inputs. It reports whether there were no errors.
+func run(stdout, stderr io.Writer, grammarFile string, inputs []string) bool {
+	src, err := os.ReadFile(grammarFile)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return false
+	}
+	r := &shred.Renderer{Color: *color, Filename: grammarFile}
+	gr, tz, err := load(grammarFile, string(src))
+	if err != nil {
+		fmt.Fprintln(stderr, r.Error(string(src), err))
+		return false
+	}
+	if *mode != "" {
+		if gr.Mode, err = shred.ParseMode(*mode); err != nil {
+			fmt.Fprintln(stderr, err)
+			return false
+		}
+	}
+	gr.Workers = -1
+	rep := gr.Validate()
+	for _, p := range rep.Problems {
+		fmt.Fprintln(stderr, r.Problem(p))
+	}
+	if rep.HasErrors() {
+		return false
+	}
+	ok := true
+	if err := gr.Build(); err != nil {
+		fmt.Fprintln(stderr, r.Error(string(src), err))
+		ok = false
+	}
+	for _, w := range gr.Check() {
+		if w.Code == shred.CodeShadowedIdent {
+			fmt.Fprintln(stderr, r.Warning(w))
+		}
+	}
+	if ok && *output != "" {
+		if err := generate(gr, *output, *pkg); err != nil {
+			fmt.Fprintln(stderr, err)
+			ok = false
+		}
+	}
+	if *yacc != "" {
+		if err := writeFile(*yacc, func(w io.Writer) error { return gr.WriteYacc(w, *pkg) }); err != nil {
+			fmt.Fprintln(stderr, err)
+			ok = false
+		}
+	}
+	if *ts != "" {
+		name := strings.TrimSuffix(filepath.Base(grammarFile), filepath.Ext(grammarFile))
+		if err := writeFile(*ts, func(w io.Writer) error { return gr.WriteTreeSitter(w, name) }); err != nil {
+			fmt.Fprintln(stderr, err)
+			ok = false
+		}
+	}
+	if *stats {
+		fmt.Fprintf(stdout, "%+v\n", gr.Stats())
+	}
+	if *report {
+		gr.WriteReport(stdout)
+	}
+	if *dot {
+		gr.WriteDOT(stdout)
+	}
+	if *js {
+		gr.WriteJSON(stdout)
+	}
+	if *input != "" && !parse(stdout, stderr, gr, tz, "-e", *input) {
+		ok = false
+	}
+	for _, name := range inputs {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			ok = false
+			continue
+		}
+		if !parse(stdout, stderr, gr, tz, name, string(src)) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// load reads a grammar in the textual format or a YAML spec if the file has a .yaml or .yml extension.
+// The builders of specs are ignored.
+func load(name, src string) (*shred.Grammar, *shred.Tokeniser, error) {
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		spec, err := shred.ReadSpec(strings.NewReader(src))
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range spec.Rules {
+			spec.Rules[i].Builder = ""
+		}
+		for i := range spec.Operators {
+			for j := range spec.Operators[i].Levels {
+				spec.Operators[i].Levels[j].Builder = ""
+			}
+		}
+		gr, err := spec.Grammar(nil)
+		return gr, spec.Tokeniser(), err
+	}
+	rules, err := shred.ParseRules(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	return shred.NewGrammar(rules), new(shred.Tokeniser), nil
+}
+
+// parse parses an input and prints its tree or the error.
+func parse(stdout, stderr io.Writer, gr *shred.Grammar, tz *shred.Tokeniser, name, src string) bool {
+	v, err := gr.Parse(tz.TokeniseString(src))
+	if err != nil {
+		r := &shred.Renderer{Color: *color, Filename: name}
+		fmt.Fprintln(stderr, r.Error(src, err))
+		return false
+	}
+	fmt.Fprintf(stdout, "%s: %s\n", name, strings.TrimSpace(fmt.Sprint(v)))
+	return true
+}
+
+// generate writes the generated parser of a grammar to a file.
+func generate(gr *shred.Grammar, output, pkg string) error {
+	if pkg == "" {
+		return errors.New("no package for the generated parser, use -pkg")
+	}
+	return writeFile(output, func(w io.Writer) error { return gr.WriteGo(w, pkg) })
+}
+
+// writeFile writes a file with a function. Nothing is written if the function fails.
+func writeFile(name string, fn func(io.Writer) error) error {
+	var b bytes.Buffer
+	if err := fn(&b); err != nil {
+		return err
+	}
+	return os.WriteFile(name, b.Bytes(), 0o666)
+}
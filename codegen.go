@@ -0,0 +1,289 @@
+package shred
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// builderNames returns the names of the generated builders of rules. Rules are numbered per left-hand
+// side and named after it, e.g. E_1 for the first rule for E. Left-hand sides with clashing names get a suffix.
+func builderNames(rules []*Rule) []string {
+	yn := &yaccNames{taken: make(map[string]bool), names: make(map[Symbol]string)}
+	counts := make(map[string]int)
+	names := make([]string, len(rules))
+	for i, r := range rules {
+		lhs := NonTerminal{r.Lhs}
+		yn.add(lhs, builderPrefix(r.Lhs))
+		counts[r.Lhs]++
+		names[i] = yn.names[lhs] + "_" + strconv.Itoa(counts[r.Lhs])
+	}
+	return names
+}
+
+// builderPrefix returns the name of a left-hand side used in the names of its rules' builders.
+func builderPrefix(lhs string) string {
+	if lhs == "0" {
+		lhs = "Start"
+	}
+	var sb strings.Builder
+	for i, c := range lhs {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) && i > 0:
+			if i == 0 {
+				c = unicode.ToUpper(c)
+			}
+			sb.WriteRune(c)
+		case unicode.IsDigit(c):
+			sb.WriteString("R")
+			sb.WriteRune(c)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+func writeInts(sb *strings.Builder, name string, xs []int32) {
+	fmt.Fprintf(sb, "var %s = [...]int32{", name)
+	for i, x := range xs {
+		if i%20 == 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(sb, "%d, ", x)
+	}
+	sb.WriteString("\n}\n\n")
+}
+
+// WriteGo writes a standalone parser for a built grammar as a Go source file of the given package.
+// The file contains the parse tables, a driver and a Builders struct with a typed builder function
+// for every rule, and doesn't depend on shred at run time. Tokens produced by shred's tokenisers
+// can be passed to the generated Parse function directly.
+func (gr *Grammar) WriteGo(w io.Writer, pkg string) error {
+	a, err := gr.built()
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Code generated by shred; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	sb.WriteString(genPrelude)
+	sb.WriteString("// TablesVersion is the version of the format of the tables of the parser and Fingerprint is\n")
+	sb.WriteString("// the fingerprint of its grammar as returned by Grammar.Fingerprint, so that a stale parser can be detected.\n")
+	fmt.Fprintf(&sb, "const (\n\tTablesVersion = %d\n\tFingerprint = %q\n)\n\n", tablesVersion, fingerprint(a.rules, a.mode))
+	fmt.Fprintf(&sb, "const (\n\tnumTerms = %d\n\tidentTerm = %d\n\teofTerm = %d\n", len(a.terms), a.identID, a.eofID)
+	fmt.Fprintf(&sb, "\tintTerm = %d\n\tfloatTerm = %d\n\tstringTerm = %d\n\trawStringTerm = %d\n\tcharTerm = %d\n)\n\n",
+		a.litIDs[KindInt], a.litIDs[KindFloat], a.litIDs[KindString], a.litIDs[KindRawString], a.litIDs[KindChar])
+	sb.WriteString("var matchTerms = map[string]int{\n")
+	for i, t := range a.terms {
+		if m, ok := t.(Match); ok {
+			fmt.Fprintf(&sb, "\t%q: %d,\n", m.Text, i)
+		}
+	}
+	sb.WriteString("}\n\nvar termNames = [...]string{\n")
+	for _, t := range a.terms {
+		fmt.Fprintf(&sb, "\t%q,\n", describe(t))
+	}
+	sb.WriteString("}\n\n")
+	writeInts(&sb, "defaults", a.defaults)
+	writeInts(&sb, "actBase", a.actBase)
+	writeInts(&sb, "actNext", a.actNext)
+	writeInts(&sb, "actCheck", a.actCheck)
+	writeInts(&sb, "gotoBase", a.gotoBase)
+	writeInts(&sb, "gotoNext", a.gotoNext)
+	writeInts(&sb, "gotoCheck", a.gotoCheck)
+	writeInts(&sb, "ruleLhs", a.ruleLhs)
+	lens := make([]int32, len(a.rules))
+	for i, r := range a.rules {
+		lens[i] = int32(len(r.Rhs))
+		if r.Lhs == "0" {
+			lens[i] = -lens[i] - 1
+		}
+	}
+	sb.WriteString("// ruleLen holds the lengths of the rules' right-hand sides, n as -n-1 for rules of the start symbol.\n")
+	writeInts(&sb, "ruleLen", lens)
+
+	names := builderNames(a.rules)
+	sb.WriteString("// Builders holds the builders of the rules. Terminals are passed as tokens, non-terminals as the values\n")
+	sb.WriteString("// built for them. Rules without builders produce the value of their only symbol or a slice of the values.\n")
+	sb.WriteString("type Builders struct {\n")
+	for i, r := range a.rules {
+		params := make([]string, len(r.Rhs))
+		for j, s := range r.Rhs {
+			typ := "interface{}"
+			if _, ok := s.(Terminal); ok {
+				typ = "Token"
+			}
+			params[j] = fmt.Sprintf("a%d %s", j, typ)
+		}
+		fmt.Fprintf(&sb, "\t// %s\n\t%s func(%s) (interface{}, error)\n", r, names[i], strings.Join(params, ", "))
+	}
+	sb.WriteString("}\n\nfunc (b *Builders) build(rule int, args []interface{}) (interface{}, error) {\n\tswitch rule {\n")
+	for i, r := range a.rules {
+		args := make([]string, len(r.Rhs))
+		for j, s := range r.Rhs {
+			args[j] = fmt.Sprintf("args[%d]", j)
+			if _, ok := s.(Terminal); ok {
+				args[j] += ".(Token)"
+			}
+		}
+		fmt.Fprintf(&sb, "\tcase %d:\n\t\tif b.%s != nil {\n\t\t\treturn b.%s(%s)\n\t\t}\n", i, names[i], names[i], strings.Join(args, ", "))
+	}
+	sb.WriteString("\t}\n\tif len(args) == 1 {\n\t\treturn args[0], nil\n\t}\n\treturn append([]interface{}(nil), args...), nil\n}\n\n")
+	sb.WriteString(genDriver)
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+const genPrelude = `import (
+	"fmt"
+	"strings"
+)
+
+// Token is a token accepted by the parser. The tokens of shred implement it.
+type Token interface {
+	Text() string
+	Line() int
+	Column() int
+	IsEOF() bool
+	IsIdent() bool
+	IsKeyword() bool
+	IsInt() bool
+	IsFloat() bool
+	IsString() bool
+	IsRawString() bool
+	IsChar() bool
+	IsWhitespace() bool
+	IsComment() bool
+}
+
+`
+
+const genDriver = `// SyntaxError is returned for a token the parser doesn't expect.
+type SyntaxError struct {
+	Token    Token
+	Expected []string
+}
+
+func (e *SyntaxError) Error() string {
+	exp := e.Expected
+	list := strings.Join(exp, "")
+	if len(exp) > 1 {
+		list = strings.Join(exp[:len(exp)-1], ", ") + " or " + exp[len(exp)-1]
+	}
+	got := "'" + e.Token.Text() + "'"
+	if e.Token.IsEOF() {
+		got = "end of input"
+	}
+	return fmt.Sprintf("%d:%d: unexpected %s, expected %s", e.Token.Line(), e.Token.Column(), got, list)
+}
+
+func lookup(base int32, next, check []int32, index int) int32 {
+	if i := int(base) + index; i >= 0 && i < len(check) && check[i] == int32(index) {
+		return next[i]
+	}
+	return 0
+}
+
+func actionAt(st, term int) int32 {
+	if act := lookup(actBase[st], actNext[:], actCheck[:], term); act != 0 {
+		return act
+	}
+	return defaults[st]
+}
+
+func literalAction(st, t int) int32 {
+	if t < 0 {
+		return 0
+	}
+	return actionAt(st, t)
+}
+
+func action(st int, tok Token) int32 {
+	switch {
+	case tok.IsEOF():
+		return actionAt(st, eofTerm)
+	case tok.IsIdent():
+		if t, ok := matchTerms[tok.Text()]; ok {
+			if act := actionAt(st, t); act != 0 {
+				return act
+			}
+		}
+		if t := identTerm; t >= 0 {
+			return actionAt(st, t)
+		}
+	case tok.IsInt():
+		return literalAction(st, intTerm)
+	case tok.IsFloat():
+		return literalAction(st, floatTerm)
+	case tok.IsString():
+		return literalAction(st, stringTerm)
+	case tok.IsRawString():
+		return literalAction(st, rawStringTerm)
+	case tok.IsChar():
+		return literalAction(st, charTerm)
+	default:
+		if t, ok := matchTerms[tok.Text()]; ok {
+			return actionAt(st, t)
+		}
+	}
+	return 0
+}
+
+// Parse parses a sequence of tokens ending with an EOF token. Whitespace and comments are skipped.
+// A nil b is treated as Builders without any builders.
+func Parse[T Token](tokens []T, b *Builders) (interface{}, error) {
+	if b == nil {
+		b = &Builders{}
+	}
+	states := []int{0}
+	var stack []interface{}
+	for i := 0; ; {
+		tok := Token(tokens[i])
+		if tok.IsWhitespace() || tok.IsComment() {
+			i++
+			continue
+		}
+		st := states[len(states)-1]
+		act := action(st, tok)
+		switch {
+		case act == 0:
+			var exp []string
+			for t := 0; t < numTerms; t++ {
+				if actionAt(st, t) != 0 {
+					exp = append(exp, termNames[t])
+				}
+			}
+			return nil, &SyntaxError{tok, exp}
+		case act > 0:
+			stack = append(stack, tok)
+			states = append(states, int(act-1))
+			i++
+		default:
+			rule := int(-act - 1)
+			l := int(ruleLen[rule])
+			start := l < 0
+			if start {
+				l = -l - 1
+			}
+			v, err := b.build(rule, stack[len(stack)-l:])
+			if err != nil {
+				return nil, err
+			}
+			if start {
+				return v, nil
+			}
+			stack = append(stack[:len(stack)-l], v)
+			states = states[:len(states)-l]
+			next := lookup(gotoBase[ruleLhs[rule]], gotoNext[:], gotoCheck[:], states[len(states)-1])
+			states = append(states, int(next-1))
+		}
+	}
+}
+`
@@ -0,0 +1,81 @@
+package shred
+
+import "errors"
+
+// Code is a stable identifier of a kind of diagnostic.
+// Codes starting with S are syntax errors, G grammar errors, W grammar warnings and I internal errors.
+type Code string
+
+const (
+	CodeUnexpectedToken Code = "S0001" // unexpected token
+	CodeUnexpectedEOF   Code = "S0002" // unexpected end of input
+	CodeBuilder         Code = "S0003" // error returned by a builder or handler
+	CodeResultType      Code = "S0004" // parse result of an unexpected type
+	CodeLimit           Code = "S0005" // parse limit exceeded
+	CodeEscape          Code = "S0006" // invalid escape sequence in a literal
+	CodeUndeclared      Code = "S0007" // reference to an undeclared name
+	CodeRedeclared      Code = "S0008" // name declared twice in a scope
+	CodeInvalidEdit     Code = "S0009" // edit of a document with an invalid range
+	CodeConflict        Code = "G0001" // reduce/reduce conflict
+	CodeGrammarSyntax   Code = "G0002" // syntax error in a textual grammar
+	CodeNil             Code = "G0003" // nil rule or symbol
+	CodeNoStart         Code = "G0004" // no rule for the start symbol
+	CodeUndefined       Code = "G0005" // non-terminal without rules
+	CodeModified        Code = "G0006" // rules changed after the grammar was built
+	CodeNotBuilt        Code = "G0007" // grammar used before it was built
+	CodeStaleTables     Code = "G0008" // saved tables of another format or grammar
+	CodeNotLiteral      Code = "G0009" // literal terminal of a kind without literals
+	CodeCorruptTables   Code = "G0010" // saved tables with inconsistent contents
+	CodeUnknownBuilder  Code = "G0011" // builder name without a builder or rule
+	CodeUnsupported     Code = "G0012" // operation the grammar doesn't support
+	CodeUnproducible    Code = "W0001" // match terminal never produced by the tokeniser
+	CodeShadowedIdent   Code = "W0002" // identifier terminal shadowed by a keyword match
+	CodeDuplicateRule   Code = "W0003" // rules differing only in their builders
+	CodeUnreachable     Code = "W0004" // rule unreachable from the start symbol
+	CodeUnproductive    Code = "W0005" // non-terminal deriving no string of terminals
+	CodeInternal        Code = "I0001" // inconsistent parse tables
+)
+
+var categoryCodes = []struct {
+	err  error
+	code Code
+}{
+	{ErrUnexpectedToken, CodeUnexpectedToken},
+	{ErrUnexpectedEOF, CodeUnexpectedEOF},
+	{ErrResultType, CodeResultType},
+	{ErrLimit, CodeLimit},
+	{ErrEscape, CodeEscape},
+	{ErrUndeclared, CodeUndeclared},
+	{ErrRedeclared, CodeRedeclared},
+	{ErrConflict, CodeConflict},
+	{ErrGrammarSyntax, CodeGrammarSyntax},
+	{ErrModified, CodeModified},
+	{ErrNotBuilt, CodeNotBuilt},
+	{ErrStaleTables, CodeStaleTables},
+	{ErrCorruptTables, CodeCorruptTables},
+	{ErrUnknownBuilder, CodeUnknownBuilder},
+	{ErrUnsupported, CodeUnsupported},
+	{ErrInvalidEdit, CodeInvalidEdit},
+	{ErrInternal, CodeInternal},
+}
+
+// ErrorCode returns the code of an error returned by the package.
+// Errors of other origin that are wrapped in parse errors have CodeBuilder, other errors have no code.
+func ErrorCode(err error) Code {
+	for _, c := range categoryCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return CodeBuilder
+	}
+	return ""
+}
+
+// Code returns the code of the error.
+func (e *ParseError) Code() Code { return ErrorCode(e) }
+
+// Code returns the code of the conflict.
+func (c Conflict) Code() Code { return CodeConflict }
@@ -0,0 +1,117 @@
+package shred
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Coverage is a tracer that records which rules and table entries were used by parses.
+// Pass it to ParseTrace for every input of a corpus and then query or report it.
+type Coverage struct {
+	gr      *Grammar
+	rules   map[*Rule]int
+	shifts  map[[2]int]struct{}
+	reduces map[int]struct{}
+	gotos   map[[2]int]struct{}
+}
+
+// NewCoverage creates an empty coverage of a built grammar.
+func NewCoverage(gr *Grammar) *Coverage {
+	return &Coverage{
+		gr:      gr,
+		rules:   make(map[*Rule]int),
+		shifts:  make(map[[2]int]struct{}),
+		reduces: make(map[int]struct{}),
+		gotos:   make(map[[2]int]struct{}),
+	}
+}
+
+// Shift records a shift.
+func (c *Coverage) Shift(state int, tok Token, next int) {
+	c.shifts[[2]int{state, next}] = struct{}{}
+}
+
+// Reduce records a reduction.
+func (c *Coverage) Reduce(state int, rule *Rule) {
+	c.rules[rule]++
+	c.reduces[state] = struct{}{}
+}
+
+// Goto records a goto.
+func (c *Coverage) Goto(state int, nt string, next int) {
+	c.gotos[[2]int{state, next}] = struct{}{}
+}
+
+// RuleCount returns how many times a rule was reduced.
+func (c *Coverage) RuleCount(r *Rule) int { return c.rules[r] }
+
+// UnusedRules returns the rules that were never reduced.
+func (c *Coverage) UnusedRules() []*Rule {
+	var ret []*Rule
+	for _, r := range c.gr.Rules {
+		if c.rules[r] == 0 {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// Entries returns the number of used and all table entries.
+// Shifts and gotos are counted per edge, reductions per state.
+func (c *Coverage) Entries() (used, total int) {
+	a, _ := c.gr.current()
+	if a == nil {
+		return 0, 0
+	}
+	for _, s := range a.states {
+		ts, as := a.stateActions(s)
+		reduces := false
+		for _, t := range ts {
+			switch act := as[t].(type) {
+			case shift:
+				total++
+				if _, ok := c.shifts[[2]int{s.id, act.state.id}]; ok {
+					used++
+				}
+			case reduce:
+				reduces = true
+			}
+		}
+		if reduces {
+			total++
+			if _, ok := c.reduces[s.id]; ok {
+				used++
+			}
+		}
+		nts, gt := a.stateGotos(s)
+		for _, nt := range nts {
+			total++
+			if _, ok := c.gotos[[2]int{s.id, gt[nt].id}]; ok {
+				used++
+			}
+		}
+	}
+	return used, total
+}
+
+// WriteReport writes the number of reductions of every rule and the table coverage,
+// marking the rules that were never reduced.
+func (c *Coverage) WriteReport(w io.Writer) error {
+	var sb strings.Builder
+	unused := 0
+	for _, r := range c.gr.Rules {
+		n := c.rules[r]
+		mark := ""
+		if n == 0 {
+			mark = "  (never reduced)"
+			unused++
+		}
+		fmt.Fprintf(&sb, "%8d  %s%s\n", n, r, mark)
+	}
+	used, total := c.Entries()
+	fmt.Fprintf(&sb, "rules: %d of %d reduced\n", len(c.gr.Rules)-unused, len(c.gr.Rules))
+	fmt.Fprintf(&sb, "table entries: %d of %d used\n", used, total)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
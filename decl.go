@@ -0,0 +1,71 @@
+package shred
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Define creates a grammar from a compact declaration for small grammars defined in Go code:
+//
+//	gr, err := shred.Define(map[string][]string{
+//		"0": {"E"},
+//		"E": {`E "+" T`, "T"},
+//		"T": {"_ident_", `"(" E ")"`},
+//	}, map[string]func([]interface{}) interface{}{
+//		`E -> E "+" T`: func(args []interface{}) interface{} { return []interface{}{args[0], args[2]} },
+//		"T":            func(args []interface{}) interface{} { return args[0] },
+//	})
+//
+// The rules map left-hand sides to their alternatives, which are written in the notation
+// of the right-hand sides of ParseRules. The rules of the start symbol 0 come first and
+// the others follow ordered by their left-hand sides, alternatives in the order given.
+// Builders are keyed by a rule written as "Lhs -> alternative" with the alternative as in
+// the rules or by a left-hand side, in which case they're the builders of its rules that have
+// none of their own. Keys that match no rule are errors. The grammar isn't built.
+func Define(rules map[string][]string, builders map[string]func([]interface{}) interface{}) (*Grammar, error) {
+	lhss := make([]string, 0, len(rules))
+	for lhs := range rules {
+		lhss = append(lhss, lhs)
+	}
+	sort.Slice(lhss, func(i, j int) bool {
+		if (lhss[i] == "0") != (lhss[j] == "0") {
+			return lhss[i] == "0"
+		}
+		return lhss[i] < lhss[j]
+	})
+	known := make(map[string]bool)
+	gr := NewGrammar(nil)
+	for _, lhs := range lhss {
+		for _, alt := range rules[lhs] {
+			key := lhs + " -> " + strings.TrimSpace(alt)
+			rs, err := ParseRules(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			if len(rs) != 1 || rs[0].Lhs != lhs {
+				return nil, newError(ErrGrammarSyntax, strconv.Quote(key)+" isn't a single alternative")
+			}
+			r := rs[0]
+			if b, ok := builders[key]; ok {
+				r.Builder = b
+			} else {
+				r.Builder = builders[lhs]
+			}
+			known[key], known[lhs] = true, true
+			gr.Rules = append(gr.Rules, r)
+		}
+	}
+	keys := make([]string, 0, len(builders))
+	for key := range builders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !known[key] {
+			return nil, newError(ErrUnknownBuilder, "builder "+strconv.Quote(key)+" matches no rule")
+		}
+	}
+	return gr, nil
+}
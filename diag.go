@@ -0,0 +1,76 @@
+package shred
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// RenderError renders an error for end users followed by its code if it has one.
+// If it's a parse error, the offending source line is shown with a caret under the token:
+//
+//	1:5: unexpected '+', expected an identifier [S0001]
+//	a + + b
+//	    ^
+func RenderError(src string, err error) string {
+	return render(src, err, nil)
+}
+
+// render renders an error with a catalog, which may be nil.
+func render(src string, err error, cat *Catalog) string {
+	if err == nil {
+		return ""
+	}
+	msg := cat.Message(err)
+	if code := ErrorCode(err); code != "" {
+		msg += " [" + string(code) + "]"
+	}
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		return msg
+	}
+	line, ok := NewSourceIndex(src).LineText(perr.Pos.Line)
+	if !ok {
+		return msg
+	}
+	return msg + "\n" + line + "\n" + caret(line, perr.Pos.Column, tokenWidth(perr.Token))
+}
+
+// tokenWidth returns the number of characters of the first line of a token (at least 1).
+func tokenWidth(tok Token) int {
+	if tok == nil {
+		return 1
+	}
+	raw := tok.Raw()
+	if i := strings.IndexByte(raw, '\n'); i >= 0 {
+		raw = raw[:i]
+	}
+	if n := utf8.RuneCountInString(raw); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// caret returns a line marking width characters from a column of a source line.
+// Tabs before the column are kept so that the marker is aligned.
+func caret(line string, col, width int) string {
+	var sb strings.Builder
+	i := 1
+	for _, c := range line {
+		if i >= col {
+			break
+		}
+		if c == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+		i++
+	}
+	for ; i < col; i++ {
+		sb.WriteByte(' ')
+	}
+	sb.WriteByte('^')
+	sb.WriteString(strings.Repeat("~", width-1))
+	return sb.String()
+}
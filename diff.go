@@ -0,0 +1,350 @@
+package shred
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is the kind of a change between two parse results.
+type ChangeKind byte
+
+const (
+	ChangeInsert ChangeKind = iota // subtree only in the new result
+	ChangeDelete                   // subtree only in the old result
+	ChangeMove                     // subtree in both results under different parents or in another order
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeInsert:
+		return "insert"
+	case ChangeDelete:
+		return "delete"
+	}
+	return "move"
+}
+
+// Change is a change of a subtree between two parse results.
+type Change struct {
+	Kind    ChangeKind
+	Old     interface{} // subtree in the old result or nil for insertions
+	New     interface{} // subtree in the new result or nil for deletions
+	OldSpan Span        // source range of the old subtree
+	NewSpan Span        // source range of the new subtree
+}
+
+// String describes the change with the source ranges of the subtrees.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeInsert:
+		return "insert " + c.NewSpan.String()
+	case ChangeDelete:
+		return "delete " + c.OldSpan.String()
+	}
+	return "move " + c.OldSpan.String() + " to " + c.NewSpan.String()
+}
+
+// Diff compares two parse results, such as Nodes of CSTs or user ASTs, and returns the subtrees
+// inserted, deleted and moved to get from the old result to the new one. The trees are those
+// walked by Inspect except that the lists built by recursive rules are flattened: the children
+// of Nodes with the same left-hand side as their parents take their places. Values are labelled
+// by their left-hand sides for Nodes, kinds and texts for tokens and types and the fields that
+// aren't children for other values, so positions are ignored.
+// Identical subtrees are matched first, then values with the same label whose children
+// are mostly matched with each other and then the remaining children of matched values
+// with the same label in order; values that stay unmatched are inserted or deleted.
+// Deletions come first in the order of the old tree, then insertions and moves in the order
+// of the new tree. Inserted subtrees may contain moved ones. The source ranges are those
+// of the values that have a Span method and otherwise those of the tokens in them.
+func Diff(old, new interface{}) []Change {
+	o, n := newDiffTree(old, nil, 0), newDiffTree(new, nil, 0)
+	matchIdentical(o, n)
+	matchContainers(n)
+	if o.match == nil && n.match == nil && o.label == n.label {
+		o.match, n.match = n, o
+	}
+	n.walk(func(t *diffNode) {
+		if t.match != nil {
+			recoverChildren(t.match, t)
+		}
+	})
+	var changes []Change
+	o.walk(func(t *diffNode) {
+		if t.match == nil && (t.parent == nil || t.parent.match != nil) {
+			changes = append(changes, Change{Kind: ChangeDelete, Old: t.v, OldSpan: t.span()})
+		}
+	})
+	moved := reordered(n)
+	n.walk(func(t *diffNode) {
+		switch {
+		case t.match == nil:
+			if t.parent == nil || t.parent.match != nil {
+				changes = append(changes, Change{Kind: ChangeInsert, New: t.v, NewSpan: t.span()})
+			}
+		case t.parent != nil && (t.parent.match != t.match.parent || moved[t]):
+			changes = append(changes, Change{ChangeMove, t.match.v, t.v, t.match.span(), t.span()})
+		}
+	})
+	return changes
+}
+
+// diffNode is a value of a parse result being compared.
+type diffNode struct {
+	v      interface{}
+	label  string
+	hash   uint64 // of the label and the hashes of the children
+	kids   []*diffNode
+	parent *diffNode
+	index  int // among the children of the parent
+	match  *diffNode
+}
+
+func newDiffTree(v interface{}, parent *diffNode, index int) *diffNode {
+	t := &diffNode{v: v, label: diffLabel(v), parent: parent, index: index}
+	h := fnv.New64a()
+	h.Write([]byte(t.label))
+	for i, c := range diffChildren(v) {
+		k := newDiffTree(c, t, i)
+		t.kids = append(t.kids, k)
+		fmt.Fprintf(h, "\x00%x", k.hash)
+	}
+	t.hash = h.Sum64()
+	return t
+}
+
+// diffChildren returns the children of a value. The children of Nodes with the same left-hand
+// side as their parent, the tails of lists built by recursive rules, take their places.
+func diffChildren(v interface{}) []interface{} {
+	n, ok := v.(*Node)
+	if !ok {
+		return Children(v)
+	}
+	var ret []interface{}
+	for _, c := range n.children {
+		if cn, ok := c.(*Node); ok && cn.rule.Lhs == n.rule.Lhs {
+			ret = append(ret, diffChildren(cn)...)
+		} else {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// walk calls fn for the node and its descendants in pre-order.
+func (t *diffNode) walk(fn func(*diffNode)) {
+	fn(t)
+	for _, k := range t.kids {
+		k.walk(fn)
+	}
+}
+
+// span returns the source range of the value.
+func (t *diffNode) span() Span {
+	switch v := t.v.(type) {
+	case Token:
+		return Span{v.Pos(), End(v)}
+	case interface{ Span() Span }:
+		return v.Span()
+	}
+	var first, last Token
+	Inspect(t.v, func(v interface{}) bool {
+		if tok, ok := v.(Token); ok && !tok.IsEOF() {
+			if first == nil {
+				first = tok
+			}
+			last = tok
+		}
+		return true
+	})
+	if first == nil {
+		return Span{}
+	}
+	return Span{first.Pos(), End(last)}
+}
+
+// diffLabel returns the label of a value, which identical values share.
+func diffLabel(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case *Node:
+		return "node " + v.rule.Lhs
+	case *ErrorNode:
+		return "error"
+	case Token:
+		return "token " + v.Kind().String() + " " + v.Text()
+	case []interface{}:
+		return "list"
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		if isBasicKind(rv.Kind()) {
+			return fmt.Sprintf("%T %v", v, v)
+		}
+		return fmt.Sprintf("%T", v)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%T", v)
+	t := rv.Type()
+	for i := 0; i < rv.NumField(); i++ {
+		if f := t.Field(i); f.IsExported() && f.Tag.Get("shred") != "-" && isBasicKind(f.Type.Kind()) {
+			fmt.Fprintf(&sb, " %s=%v", f.Name, rv.Field(i))
+		}
+	}
+	return sb.String()
+}
+
+func isBasicKind(k reflect.Kind) bool {
+	return k == reflect.Bool || k == reflect.String ||
+		k >= reflect.Int && k <= reflect.Uint64 || k == reflect.Float32 || k == reflect.Float64
+}
+
+// matchSubtrees matches identical subtrees node by node except for nodes matched before.
+func matchSubtrees(o, n *diffNode) {
+	if o.match == nil && n.match == nil {
+		o.match, n.match = n, o
+	}
+	for i := range o.kids {
+		matchSubtrees(o.kids[i], n.kids[i])
+	}
+}
+
+// matchIdentical matches the identical subtrees of the new tree that aren't leaves with those
+// of the old tree, top-down and each with the first unmatched one in the old tree.
+func matchIdentical(o, n *diffNode) {
+	byHash := make(map[uint64][]*diffNode)
+	o.walk(func(t *diffNode) {
+		if len(t.kids) > 0 {
+			byHash[t.hash] = append(byHash[t.hash], t)
+		}
+	})
+	n.walk(func(t *diffNode) {
+		if t.match != nil || len(t.kids) == 0 {
+			return
+		}
+		for _, c := range byHash[t.hash] {
+			if c.match == nil && c.label == t.label {
+				matchSubtrees(c, t)
+				return
+			}
+		}
+	})
+}
+
+// matchContainers matches the unmatched nodes of the new tree, top-down, with unmatched nodes
+// of the old tree with the same label that contain the most matches of their descendants.
+func matchContainers(n *diffNode) {
+	n.walk(func(t *diffNode) {
+		if t.match != nil || len(t.kids) == 0 {
+			return
+		}
+		score := make(map[*diffNode]int)
+		var best *diffNode
+		t.matched(func(d *diffNode) {
+			for c := d.match.parent; c != nil; c = c.parent {
+				if c.match == nil && c.label == t.label {
+					score[c]++
+					if best == nil || score[c] > score[best] {
+						best = c
+					}
+				}
+			}
+		})
+		if best != nil {
+			best.match, t.match = t, best
+		}
+	})
+}
+
+// matched calls fn for the matched descendants of t that aren't descendants of matched ones.
+func (t *diffNode) matched(fn func(*diffNode)) {
+	for _, k := range t.kids {
+		if k.match != nil {
+			fn(k)
+		} else {
+			k.matched(fn)
+		}
+	}
+}
+
+// recoverChildren matches the unmatched children of matched nodes in order, with identical
+// children first and then with children with the same label.
+func recoverChildren(o, n *diffNode) {
+	for _, same := range []func(a, b *diffNode) bool{
+		func(a, b *diffNode) bool { return a.hash == b.hash && a.label == b.label },
+		func(a, b *diffNode) bool { return a.label == b.label },
+	} {
+		j := 0
+		for _, nk := range n.kids {
+			if nk.match != nil {
+				continue
+			}
+			for k := j; k < len(o.kids); k++ {
+				if ok := o.kids[k]; ok.match == nil && same(ok, nk) {
+					if ok.hash == nk.hash {
+						matchSubtrees(ok, nk)
+					} else {
+						ok.match, nk.match = nk, ok
+					}
+					j = k + 1
+					break
+				}
+			}
+		}
+	}
+}
+
+// reordered returns the nodes of the new tree whose matches are children of the match
+// of their parent but out of order: those outside the longest run of children in order.
+func reordered(n *diffNode) map[*diffNode]bool {
+	moved := make(map[*diffNode]bool)
+	n.walk(func(t *diffNode) {
+		if t.match == nil {
+			return
+		}
+		var kids []*diffNode
+		for _, k := range t.kids {
+			if k.match != nil && k.match.parent == t.match {
+				kids = append(kids, k)
+			}
+		}
+		in := longestIncreasing(kids)
+		for _, k := range kids {
+			if !in[k] {
+				moved[k] = true
+			}
+		}
+	})
+	return moved
+}
+
+// longestIncreasing returns the longest subsequence of nodes whose matches' indices increase.
+func longestIncreasing(kids []*diffNode) map[*diffNode]bool {
+	var tails []int // indices into kids of the last elements of the runs of each length
+	prev := make([]int, len(kids))
+	for i, k := range kids {
+		l := sort.Search(len(tails), func(j int) bool { return kids[tails[j]].match.index >= k.match.index })
+		prev[i] = -1
+		if l > 0 {
+			prev[i] = tails[l-1]
+		}
+		if l == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[l] = i
+		}
+	}
+	in := make(map[*diffNode]bool)
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			in[kids[i]] = true
+		}
+	}
+	return in
+}
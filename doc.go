@@ -0,0 +1,102 @@
+package shred
+
+import "strings"
+
+// Documented is implemented by values that take the doc comments preceding them.
+// User AST types that record their spans, e.g. as Positioned values, can implement it
+// to be passed to AttachDocs.
+type Documented interface {
+	Span() Span
+	SetDoc(doc []Token)
+}
+
+// AttachDocs attaches doc comments to the Documented values of a parse result.
+// The tokens are those the result was parsed from and must include the comments,
+// which the tokeniser keeps if KeepWhitespace is set. The doc comment of a value is the block
+// of comments immediately preceding its first token: comments separated by at most one line
+// break, the last of them ending on the line of the token or the line before it.
+// Comments following a token on its line belong to that line and don't start a block.
+// A block goes to the outermost value starting at its token, values without one are left alone.
+func AttachDocs(root interface{}, tokens []Token) {
+	attachDocs(root, tokens, func(v interface{}, doc []Token) bool {
+		d, ok := v.(Documented)
+		if !ok {
+			return false
+		}
+		if span := d.Span(); span.End.Offset == span.Start.Offset {
+			return false
+		}
+		d.SetDoc(doc)
+		return true
+	})
+}
+
+// attachDocs passes the doc comments of the values of a parse result to take, which reports
+// whether the value took the comment. Values are visited outermost first.
+func attachDocs(root interface{}, tokens []Token, take func(v interface{}, doc []Token) bool) {
+	docs := docComments(tokens)
+	if len(docs) == 0 {
+		return
+	}
+	Inspect(root, func(v interface{}) bool {
+		var start int
+		switch v := v.(type) {
+		case *Node:
+			start = v.span.Start.Offset
+		case Documented:
+			start = v.Span().Start.Offset
+		default:
+			return true
+		}
+		if doc, ok := docs[start]; ok && take(v, doc) {
+			delete(docs, start)
+		}
+		return true
+	})
+}
+
+// docComments maps the offsets of tokens to the doc comments preceding them.
+func docComments(tokens []Token) map[int][]Token {
+	docs := make(map[int][]Token)
+	var block []Token
+	var code Token // the last token that isn't trivia
+	for _, tok := range tokens {
+		switch {
+		case tok.IsWhitespace():
+		case tok.IsComment():
+			if len(block) > 0 && tok.Line() > End(block[len(block)-1]).Line+1 {
+				block = nil
+			}
+			if len(block) > 0 || code == nil || End(code).Line < tok.Line() {
+				block = append(block, tok)
+			}
+		default:
+			if len(block) > 0 && !tok.IsEOF() && tok.Line() <= End(block[len(block)-1]).Line+1 {
+				docs[tok.Pos().Offset] = block
+			}
+			block, code = nil, tok
+		}
+	}
+	return docs
+}
+
+// DocText returns the text of a doc comment: the lines of its comments without
+// the // and /* */ markers and the spaces around them.
+func DocText(doc []Token) string {
+	var lines []string
+	for _, c := range doc {
+		text := c.Raw()
+		switch {
+		case strings.HasPrefix(text, "//"):
+			lines = append(lines, strings.TrimSpace(text[2:]))
+		case strings.HasPrefix(text, "/*"):
+			text = strings.TrimSuffix(text[2:], "*/")
+			for _, l := range strings.Split(text, "\n") {
+				lines = append(lines, strings.TrimSpace(l))
+			}
+		default:
+			lines = append(lines, strings.TrimSpace(text))
+		}
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,280 @@
+package shred
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Parser is a parse in progress that can be advanced step by step.
+type Parser struct {
+	a      *automaton
+	tokens []Token
+	src    <-chan []Token // batches of tokens still to come or nil
+	b      builder
+	vb     valueBuilder // builder of pooled parsers
+	tr     Tracer
+	ctx    context.Context // context checked by Run or nil
+	stack  []interface{}
+	ranges []tokenRange
+	states []int
+	limits Limits
+	holes  map[string]string // non-terminals of placeholders
+	item   bool              // accept the start symbol before any token that can't follow it
+	count  int               // number of reductions
+	i      int
+	done   bool
+	result interface{}
+	err    error
+}
+
+// NewParser creates a parser for a sequence of tokens. Values are built by the rules' builders.
+func (gr *Grammar) NewParser(tokens []Token) *Parser {
+	return gr.newParser(tokens, gr.values(nil), nil)
+}
+
+func (gr *Grammar) newParser(tokens []Token, b builder, tr Tracer) *Parser {
+	err := gr.buildLazily()
+	a, _ := gr.current()
+	p := &Parser{holes: gr.Placeholders}
+	p.reset(a, gr.Limits, tokens, b, tr)
+	switch {
+	case err != nil:
+		p.fail(err)
+	case a == nil:
+		p.fail(ErrNotBuilt)
+	case a.stale(gr.Rules):
+		p.fail(ErrModified)
+	}
+	return p
+}
+
+// parsers holds the parsers of finished parses for reuse of their stacks.
+var parsers = sync.Pool{New: func() interface{} { return new(Parser) }}
+
+// maxPooledStack is the size of the largest stack kept for reuse.
+const maxPooledStack = 1 << 16
+
+// parse parses a sequence of tokens building values with a builder.
+// If the tracer isn't nil, it's called for every step.
+func (gr *Grammar) parse(tokens []Token, b builder, tr Tracer) (interface{}, error) {
+	v, _, err := gr.run(nil, tokens, b, nil, tr)
+	return v, err
+}
+
+// parseValues parses a sequence of tokens calling the rules' builders.
+func (gr *Grammar) parseValues(tokens []Token, vb valueBuilder, tr Tracer) (interface{}, error) {
+	v, _, err := gr.run(nil, tokens, nil, &vb, tr)
+	return v, err
+}
+
+// run performs a parse with a pooled parser until the context, which may be nil, is done,
+// and returns the index of the token where it stopped. A value builder is stored in the parser
+// so that it doesn't have to be allocated.
+func (gr *Grammar) run(ctx context.Context, tokens []Token, b builder, vb *valueBuilder, tr Tracer) (interface{}, int, error) {
+	if err := gr.buildLazily(); err != nil {
+		return nil, 0, err
+	}
+	if gr.Hooks != nil {
+		defer phaseDone(gr.Hooks, PhaseParse, time.Now())
+	}
+	if gr.Engine != nil {
+		if vb != nil {
+			b = vb
+		}
+		if ctx != nil && ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		v, err := gr.Engine.Parse(tokens, engineBuilder{b})
+		return v, len(tokens) - 1, err
+	}
+	a, err := gr.built()
+	if err != nil {
+		return nil, 0, err
+	}
+	if a.stale(gr.Rules) {
+		return nil, 0, ErrModified
+	}
+	p := parsers.Get().(*Parser)
+	if vb != nil {
+		p.vb = *vb
+		b = &p.vb
+	}
+	p.reset(a, gr.Limits, tokens, b, tr)
+	p.holes, p.ctx = gr.Placeholders, ctx
+	v, err := p.Run()
+	n := p.i
+	p.reset(nil, Limits{}, nil, nil, nil)
+	p.vb, p.holes, p.ctx = valueBuilder{}, nil, nil
+	if cap(p.stack) <= maxPooledStack {
+		parsers.Put(p)
+	}
+	return v, n, err
+}
+
+// reset prepares the parser for a new parse keeping the memory of its stacks.
+// Values left on the stack are cleared so that they can be garbage-collected.
+func (p *Parser) reset(a *automaton, limits Limits, tokens []Token, b builder, tr Tracer) {
+	for i := range p.stack {
+		p.stack[i] = nil
+	}
+	p.a, p.limits, p.tokens, p.src, p.b, p.tr = a, limits, tokens, nil, b, tr
+	p.stack, p.ranges, p.states = p.stack[:0], p.ranges[:0], p.states[:0]
+	if a != nil {
+		p.states = append(p.states, a.initState.id)
+	}
+	p.i, p.count, p.done, p.result, p.err = 0, 0, false, nil, nil
+}
+
+// Reset prepares the parser for parsing another sequence of tokens reusing the memory of its stacks.
+// It uses the same automaton, limits, builders and tracer as before.
+func (p *Parser) Reset(tokens []Token) { p.reset(p.a, p.limits, tokens, p.b, p.tr) }
+
+// SetTracer sets a tracer called for the following steps.
+func (p *Parser) SetTracer(tr Tracer) { p.tr = tr }
+
+// Run performs the remaining steps and returns the result.
+// A parse started by ParseContext fails with the context's error once the context is done.
+func (p *Parser) Run() (interface{}, error) {
+	for steps := 0; !p.done; steps++ {
+		if p.ctx != nil && steps%checkInterval == 0 {
+			if err := p.ctx.Err(); err != nil {
+				p.fail(err)
+				break
+			}
+		}
+		p.Step()
+	}
+	return p.result, p.err
+}
+
+// Result returns the result of a finished parse.
+func (p *Parser) Result() (interface{}, error) { return p.result, p.err }
+
+// Done reports whether the parse has finished.
+func (p *Parser) Done() bool { return p.done }
+
+// State returns the number of the current state.
+func (p *Parser) State() int { return p.states[len(p.states)-1] }
+
+// States returns the numbers of the states on the stack, the current one being the last.
+func (p *Parser) States() []int { return append([]int(nil), p.states...) }
+
+// Stack returns the values on the stack, the top one being the last.
+func (p *Parser) Stack() []interface{} { return append([]interface{}(nil), p.stack...) }
+
+// Pos returns the index of the next token.
+func (p *Parser) Pos() int { return p.i }
+
+// Token returns the next token.
+func (p *Parser) Token() Token { return p.tokens[p.i] }
+
+func (p *Parser) fail(err error) (bool, error) {
+	p.done, p.err = true, err
+	return true, err
+}
+
+func (p *Parser) succeed(v interface{}) (bool, error) {
+	p.done, p.result = true, v
+	return true, nil
+}
+
+// Step performs one shift, or a reduction with its goto. Trivia tokens are skipped.
+// Placeholders are shifted as the non-terminals they stand for.
+// It reports whether the parse has finished and returns the error if it has failed.
+func (p *Parser) Step() (bool, error) {
+	if p.done {
+		return true, p.err
+	}
+	a := p.a
+	if p.src != nil {
+		p.fill()
+	}
+	for IsTrivia(p.tokens[p.i]) {
+		p.i++
+		if p.src != nil {
+			p.fill()
+		}
+	}
+	tok := p.tokens[p.i]
+	st := p.states[len(p.states)-1]
+	var hole *Placeholder
+	var act int32
+	if tok.Kind() == KindPlaceholder {
+		hole = newPlaceholder(tok, p.holes)
+		act = a.placeholderAction(st, hole.NonTerminal)
+	} else {
+		act = a.action(st, tok)
+	}
+	if act == 0 && p.item {
+		if e := a.actionAt(st, int(a.eofID)); e < 0 {
+			act = e
+		}
+	}
+	if act > 0 && a.pending != nil {
+		act = a.bind(st, tok, act)
+	}
+	switch {
+	case act == 0:
+		_, as := a.stateActions(a.states[st])
+		return p.fail(unexpected(tok, as))
+	case act > 0:
+		next := int(act - 1)
+		if err := p.limits.check(len(p.stack)+1, p.count); err != nil {
+			return p.fail(positioned(err, tok))
+		}
+		var v interface{} = hole
+		if hole == nil {
+			var err error
+			if v, err = p.b.shift(p.tokens, p.i); err != nil {
+				return p.fail(positioned(err, tok))
+			}
+		}
+		p.stack = append(p.stack, v)
+		p.ranges = append(p.ranges, tokenRange{p.i, p.i + 1})
+		if p.tr != nil {
+			p.tr.Shift(st, tok, next)
+		}
+		p.states = append(p.states, next)
+		p.i++
+	default:
+		ri := int(-act - 1)
+		r := a.rules[ri]
+		p.count++
+		if err := p.limits.check(len(p.stack), p.count); err != nil {
+			return p.fail(positioned(err, tok))
+		}
+		if p.tr != nil {
+			p.tr.Reduce(st, r)
+		}
+		l := len(r.Rhs)
+		rg := tokenRange{p.i, p.i}
+		if l > 0 {
+			rg = tokenRange{p.ranges[len(p.ranges)-l].first, p.ranges[len(p.ranges)-1].last}
+		}
+		data := p.stack[len(p.stack)-l:]
+		v, err := p.b.reduce(r, data, p.tokens, rg.first, rg.last)
+		if err != nil {
+			return p.fail(positioned(err, p.tokens[rg.first]))
+		}
+		p.stack = append(p.stack[:len(p.stack)-l], v)
+		p.ranges = append(p.ranges[:len(p.ranges)-l], rg)
+		if r.Lhs == "0" {
+			if len(p.stack) != 1 {
+				panic("corrupted symbol stack")
+			}
+			return p.succeed(p.stack[0])
+		}
+		p.states = p.states[:len(p.states)-l]
+		pst := p.states[len(p.states)-1]
+		next := a.gotoState(pst, ri)
+		if next < 0 {
+			return p.fail(newError(ErrInternal, "no goto over '"+r.Lhs+"' for state "+a.stateAsString(a.states[pst])))
+		}
+		if p.tr != nil {
+			p.tr.Goto(pst, r.Lhs, next)
+		}
+		p.states = append(p.states, next)
+	}
+	return false, nil
+}
@@ -0,0 +1,29 @@
+package shred
+
+// Engine is a parsing algorithm that can replace the built-in LR driver of a grammar.
+// Build prepares it from the grammar's rules, then Parse is called for every parse with
+// the tokens, including trivia, and a Builder of the parse's values. Parse may be called
+// concurrently. Parsing with an engine honours the grammar's builders, reduce hooks and
+// Hooks, but not Limits and Tracers. Features that work with the LR automaton, such as
+// NewParser, Explain, SaveTables, WriteGo and Stats, are only available without an engine.
+type Engine interface {
+	Build(rules []*Rule) error
+	Parse(tokens []Token, b Builder) (interface{}, error)
+}
+
+// Builder builds the values of a parse for an engine. Shift returns the value of the token
+// at index i, Reduce the value of a rule with the values of its right-hand side, which cover
+// tokens[first:last]. Errors returned by them should abort the parse.
+type Builder interface {
+	Shift(tokens []Token, i int) (interface{}, error)
+	Reduce(rule *Rule, args []interface{}, tokens []Token, first, last int) (interface{}, error)
+}
+
+// engineBuilder exposes a builder to engines.
+type engineBuilder struct{ b builder }
+
+func (b engineBuilder) Shift(tokens []Token, i int) (interface{}, error) { return b.b.shift(tokens, i) }
+
+func (b engineBuilder) Reduce(rule *Rule, args []interface{}, tokens []Token, first, last int) (interface{}, error) {
+	return b.b.reduce(rule, args, tokens, first, last)
+}
@@ -0,0 +1,172 @@
+package shred
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Error categories. All errors returned by the package wrap one of them except for errors
+// returned by builders, contexts and the writers passed to the package, which are passed through.
+var (
+	ErrConflict        = errors.New("grammar conflict")
+	ErrUnexpectedToken = errors.New("unexpected token")
+	ErrUnexpectedEOF   = errors.New("unexpected end of input")
+	ErrResultType      = errors.New("unexpected parse result type")
+	ErrLimit           = errors.New("parse limit exceeded")
+	ErrEscape          = errors.New("invalid escape sequence")
+	ErrUndeclared      = errors.New("undeclared name")
+	ErrRedeclared      = errors.New("name redeclared")
+	ErrGrammarSyntax   = errors.New("grammar syntax error")
+	ErrModified        = errors.New("grammar modified since Build")
+	ErrNotBuilt        = errors.New("grammar not built: call Build or set Lazy")
+	ErrStaleTables     = errors.New("tables don't match the grammar")
+	ErrCorruptTables   = errors.New("corrupted tables")
+	ErrUnknownBuilder  = errors.New("unknown builder")
+	ErrUnsupported     = errors.New("unsupported operation")
+	ErrInvalidEdit     = errors.New("invalid edit")
+	ErrInternal        = errors.New("inconsistent parse tables")
+)
+
+// categorised is an error with a message that wraps its category.
+type categorised struct {
+	cat error
+	msg string
+}
+
+func (e *categorised) Error() string { return e.msg }
+
+func (e *categorised) Unwrap() error { return e.cat }
+
+// newError creates an error in a category.
+func newError(cat error, msg string) error { return &categorised{cat, msg} }
+
+// ParseError is an error at a position in the input.
+// Syntax errors have the offending token and the terminals that would have been accepted instead.
+type ParseError struct {
+	Pos      Position
+	Err      error
+	Token    Token
+	Expected []string
+}
+
+// Error returns the error message prefixed with the position.
+func (e *ParseError) Error() string { return e.Pos.String() + ": " + e.Err.Error() }
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// Conflict is a state of the automaton in which more than one rule can be reduced.
+type Conflict struct {
+	State string  // items of the state
+	Rules []*Rule // rules that can be reduced
+}
+
+// String describes the conflict.
+func (c Conflict) String() string {
+	rules := make([]string, len(c.Rules))
+	for i, r := range c.Rules {
+		rules[i] = r.String()
+	}
+	return "reduce/reduce conflict between " + strings.Join(rules, " and ") + " in state " + c.State
+}
+
+// ConflictError is returned by Build if the grammar has conflicts.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+// Error lists the conflicts.
+func (e *ConflictError) Error() string {
+	msgs := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		msgs[i] = c.String()
+	}
+	if len(msgs) == 1 {
+		return msgs[0]
+	}
+	return fmt.Sprintf("%d conflicts:\n%s", len(msgs), strings.Join(msgs, "\n"))
+}
+
+// Unwrap returns ErrConflict.
+func (e *ConflictError) Unwrap() error { return ErrConflict }
+
+// describe returns a description of a terminal for error messages.
+func describe(t Terminal) string {
+	switch t := t.(type) {
+	case Ident:
+		return "an identifier"
+	case EOF:
+		return "end of input"
+	case Match:
+		return "'" + t.Text + "'"
+	case Literal:
+		return literalNames[t.Kind()]
+	}
+	return t.String()
+}
+
+// literalNames describe literal terminals.
+var literalNames = map[Kind]string{
+	KindInt:       "an integer",
+	KindFloat:     "a float",
+	KindString:    "a string",
+	KindRawString: "a raw string",
+	KindChar:      "a character",
+}
+
+// describeToken returns a description of a token for error messages.
+func describeToken(tok Token) string {
+	switch {
+	case tok.IsEOF():
+		return "end of input"
+	case tok.IsIdent():
+		return "identifier '" + tok.Text() + "'"
+	case tok.IsKeyword():
+		return "keyword '" + tok.Text() + "'"
+	case tok.Kind() == KindOther:
+		return "'" + tok.Text() + "'"
+	}
+	return tok.Kind().String() + " " + tok.Raw()
+}
+
+// expected returns the sorted descriptions of the terminals of an action map.
+func expected(as map[Terminal]action) []string {
+	ret := make([]string, 0, len(as))
+	for t := range as {
+		ret = append(ret, describe(t))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// joinOr joins descriptions in the form "a, b or c".
+func joinOr(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+// Incomplete reports whether a parse failed only because the input ended, so that an interactive
+// shell can read another line and parse again instead of reporting an error. The parser detects
+// a syntax error at the first token that can't continue a valid input, so if that token is EOF,
+// the tokens before it are the beginning of a valid input.
+func Incomplete(err error) bool { return errors.Is(err, ErrUnexpectedEOF) }
+
+// unexpected returns a syntax error for a token that has no action.
+func unexpected(tok Token, as map[Terminal]action) *ParseError {
+	exp := expected(as)
+	cat := ErrUnexpectedToken
+	if tok.IsEOF() {
+		cat = ErrUnexpectedEOF
+	}
+	var err error
+	if _, ok := as[Ident{}]; ok && tok.IsKeyword() {
+		err = newError(cat, fmt.Sprintf("expected an identifier, got keyword '%s'", tok.Text()))
+	} else {
+		err = newError(cat, fmt.Sprintf("unexpected %s, expected %s", describeToken(tok), joinOr(exp)))
+	}
+	return &ParseError{tok.Pos(), err, tok, exp}
+}
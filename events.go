@@ -0,0 +1,62 @@
+package shred
+
+// EventHandler receives the events of a parse instead of values being built.
+// Events come in prefix order: a rule is entered, its tokens and rules are reported
+// and then it's exited. An error returned by the handler stops the events.
+type EventHandler interface {
+	Token(tok Token) error
+	Enter(rule *Rule, span Span) error
+	Exit(rule *Rule, span Span) error
+}
+
+// eventTree is a reduced rule whose events haven't been reported yet.
+// Its children are tokens, placeholders and trees.
+type eventTree struct {
+	rule *Rule
+	span Span
+	kids []interface{}
+}
+
+// eventBuilder records the events of a parse. The value of a token is the token,
+// the value of a rule its eventTree.
+type eventBuilder struct{}
+
+func (eventBuilder) shift(tokens []Token, i int) (interface{}, error) {
+	return tokens[i], nil
+}
+
+func (eventBuilder) reduce(r *Rule, args []interface{}, tokens []Token, first, last int) (interface{}, error) {
+	return &eventTree{r, spanOf(tokens, first, last), append([]interface{}(nil), args...)}, nil
+}
+
+// report reports the events of a value to a handler.
+func report(v interface{}, h EventHandler) error {
+	switch v := v.(type) {
+	case Token:
+		return h.Token(v)
+	case *Placeholder:
+		return h.Token(v.Token)
+	case *eventTree:
+		if err := h.Enter(v.rule, v.span); err != nil {
+			return err
+		}
+		for _, k := range v.kids {
+			if err := report(k, h); err != nil {
+				return err
+			}
+		}
+		return h.Exit(v.rule, v.span)
+	}
+	return nil
+}
+
+// ParseEvents parses a sequence of tokens reporting the parse to a handler without building any values.
+// The parser only knows a rule when it reduces it, so the events are recorded in a tree
+// of rules and tokens, which is much smaller than built values, and reported after the parse.
+func (gr *Grammar) ParseEvents(tokens []Token, h EventHandler) error {
+	v, err := gr.parse(tokens, eventBuilder{}, nil)
+	if err != nil {
+		return err
+	}
+	return report(v, h)
+}
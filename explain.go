@@ -0,0 +1,89 @@
+package shred
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context is a rule that was being parsed when a parse stopped.
+type Context struct {
+	Rule  *Rule
+	Dot   int      // number of right-hand side symbols parsed so far
+	Start Position // position of the first token of the rule
+}
+
+// String describes the context in user terms.
+func (c Context) String() string {
+	return fmt.Sprintf("while parsing %s started at line %d, column %d", c.Rule.Lhs, c.Start.Line, c.Start.Column)
+}
+
+// Contexts returns the rules in progress in the current state of the parser, innermost first.
+// Each rule is followed by the rules it's nested in. It returns nil if the grammar wasn't built.
+func (p *Parser) Contexts() []Context {
+	if p.a == nil {
+		return nil
+	}
+	type frame struct {
+		depth int // index of the state in which the item is
+		it    item
+	}
+	var ret []Context
+	seen := make(map[frame]struct{})
+	reported := make(map[[2]int]struct{}) // rule and origin of reported contexts
+	var visit func(f frame)
+	visit = func(f frame) {
+		if _, ok := seen[f]; ok {
+			return
+		}
+		seen[f] = struct{}{}
+		r := p.a.rules[f.it.rule]
+		if r.Lhs == "0" {
+			return
+		}
+		origin := f.depth - f.it.dot
+		if _, ok := reported[[2]int{f.it.rule, origin}]; !ok && f.it.dot > 0 {
+			reported[[2]int{f.it.rule, origin}] = struct{}{}
+			first := p.ranges[origin].first
+			if first >= len(p.tokens) {
+				first = len(p.tokens) - 1
+			}
+			ret = append(ret, Context{r, f.it.dot, p.tokens[first].Pos()})
+		}
+		// the items of the origin state expecting the rule's left-hand side enclose it
+		nt := NonTerminal{r.Lhs}
+		for _, it := range p.a.states[p.states[origin]].items {
+			pr := p.a.rules[it.rule]
+			if it.dot < len(pr.Rhs) && pr.Rhs[it.dot] == nt {
+				visit(frame{origin, it})
+			}
+		}
+	}
+	top := len(p.states) - 1
+	for _, it := range p.a.states[p.states[top]].items {
+		if it.dot > 0 {
+			visit(frame{top, it})
+		}
+	}
+	return ret
+}
+
+// Explain returns the error of a failed parse followed by the rules that were in progress.
+// It returns an empty string if the parse hasn't failed.
+func (p *Parser) Explain() string {
+	if p.err == nil {
+		return ""
+	}
+	lines := []string{p.err.Error()}
+	for _, c := range p.Contexts() {
+		lines = append(lines, "  "+c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Explain parses a sequence of tokens and explains why the parse failed.
+// It returns an empty string if the parse succeeds.
+func (gr *Grammar) Explain(tokens []Token) string {
+	p := gr.NewParser(tokens)
+	p.Run()
+	return p.Explain()
+}
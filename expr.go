@@ -0,0 +1,109 @@
+package shred
+
+// Fixity is the position of an operator relative to its operands.
+type Fixity byte
+
+const (
+	Infix   Fixity = iota // binary operator between its operands
+	Prefix                // unary operator before its operand
+	Postfix               // unary operator after its operand
+)
+
+// Operator is an operator of an expression. Binding powers decide which of two operators gets
+// the operand between them as in a Pratt parser: it goes to the operator on its left unless
+// the left binding power of the one on its right is at least the right binding power of the left one.
+// An infix operator is thus left-associative if its right binding power is greater than its left one
+// and right-associative otherwise. Prefix operators only have a right binding power and postfix
+// operators a left one. Reduce and Tag are those of the operator's rule.
+type Operator struct {
+	Text        string
+	Fixity      Fixity
+	Left, Right int // binding powers
+	Reduce      func(*Reduction) (interface{}, error)
+	Tag         interface{}
+}
+
+// Expression designates a non-terminal as an expression of operands and operators.
+// Its rules, returned by Rules, are Lhs -> Operand, which passes the operand through, and
+// a rule for every operator, e.g. Lhs -> Lhs "+" Lhs. These rules are ambiguous but the parser
+// doesn't resolve their conflicts by shifting: when it could either shift an operator or reduce
+// the rule of another one, it decides by their binding powers. So the rest of the grammar stays LR
+// and operators don't need a non-terminal for every precedence level.
+//
+// Parsers generated by WriteGo and engines don't know binding powers and always shift.
+// Saved tables don't record them either, so FromTables refuses the tables of such grammars.
+type Expression struct {
+	Lhs       string
+	Operand   string
+	Operators []Operator
+}
+
+// precedence is the binding powers of the rule of an infix or prefix operator.
+type precedence struct {
+	right int
+	left  map[string]int // left binding powers of the expression's infix and postfix operators
+}
+
+// Rules returns the rules of the expression.
+func (e *Expression) Rules() []*Rule {
+	lhs, left := NonTerminal{e.Lhs}, make(map[string]int)
+	rules := []*Rule{{Lhs: e.Lhs, Rhs: []Symbol{NonTerminal{e.Operand}}, Builder: func(args []interface{}) interface{} {
+		return args[0]
+	}}}
+	for _, op := range e.Operators {
+		r := &Rule{Lhs: e.Lhs, Reduce: op.Reduce, Tag: op.Tag}
+		switch op.Fixity {
+		case Prefix:
+			r.Rhs = []Symbol{Match{op.Text}, lhs}
+		case Postfix:
+			r.Rhs = []Symbol{lhs, Match{op.Text}}
+		default:
+			r.Rhs = []Symbol{lhs, Match{op.Text}, lhs}
+		}
+		if op.Fixity != Postfix {
+			r.prec = &precedence{op.Right, left}
+		}
+		if op.Fixity != Prefix {
+			left[op.Text] = op.Left
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// indexOperators finds the rules of infix and prefix operators completed in the states.
+// The grammar has no such rules if none are found.
+func (a *automaton) indexOperators() {
+	a.pending = nil
+	for _, s := range a.states {
+		for _, it := range s.items {
+			if r := a.rules[it.rule]; r.prec != nil && it.dot == len(r.Rhs) {
+				if a.pending == nil {
+					a.pending = make([]int32, len(a.states))
+					for i := range a.pending {
+						a.pending[i] = -1
+					}
+				}
+				a.pending[s.id] = int32(it.rule)
+			}
+		}
+	}
+}
+
+// bind returns the action of a state for a token that can be shifted. If the token is an operator
+// of an expression whose operator rule is completed in the state and it binds the operand less
+// tightly than that operator, the rule is reduced instead.
+func (a *automaton) bind(st int, tok Token, shift int32) int32 {
+	r := a.pending[st]
+	if r < 0 {
+		return shift
+	}
+	switch tok.Kind() {
+	case KindOther, KindIdent, KindKeyword:
+		p := a.rules[r].prec
+		if left, ok := p.left[tok.Text()]; ok && left < p.right {
+			return -r - 1
+		}
+	}
+	return shift
+}
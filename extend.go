@@ -0,0 +1,56 @@
+package shred
+
+import "time"
+
+// AddRules adds rules to the grammar, e.g. syntax extensions registered by plugins at run time.
+// If the grammar is built, its automaton is updated as by Build but only the states whose items
+// the rules change are computed again: the closures of the other states are taken over from
+// the current automaton. The parse tables are built anew. In ModeLR1, with an engine or if
+// the rules were modified since Build, the grammar is built from scratch. Conflicts are reported
+// as by Build. AddRules modifies Rules, so it must not be called during parses of the grammar;
+// a Language compiled from it can be used meanwhile.
+func (gr *Grammar) AddRules(rules ...*Rule) error {
+	a, _ := gr.current()
+	stale := a == nil || a.stale(gr.Rules)
+	gr.Rules = append(gr.Rules, rules...)
+	switch {
+	case a == nil:
+		return nil
+	case gr.Engine != nil || gr.Mode == ModeLR1 || a.mode != gr.Mode || stale:
+		return gr.Build()
+	}
+	if gr.Hooks != nil {
+		defer phaseDone(gr.Hooks, PhaseBuild, time.Now())
+	}
+	return gr.buildFrom(a)
+}
+
+// closures returns the closed items of the states of the automaton by the keys of their kernels
+// for the states whose closures don't change if the rules following the automaton's rules are added.
+// The closure of a state changes if it predicts the left-hand side of an added rule.
+// The initial state isn't included since its kernel changes with the rules of the start symbol.
+func (a *automaton) closures(rules []*Rule) map[string][]item {
+	changed := make(map[Symbol]bool)
+	for _, r := range rules[len(a.rules):] {
+		changed[NonTerminal{r.Lhs}] = true
+	}
+	ret := make(map[string][]item, len(a.states))
+states:
+	for _, s := range a.states {
+		if s == a.initState {
+			continue
+		}
+		kernel := &state{}
+		for _, it := range s.items {
+			rhs := a.rules[it.rule].Rhs
+			if it.dot < len(rhs) && changed[rhs[it.dot]] {
+				continue states
+			}
+			if it.dot > 0 {
+				kernel.items = append(kernel.items, it)
+			}
+		}
+		ret[kernel.key()] = s.items
+	}
+	return ret
+}
@@ -0,0 +1,151 @@
+package shred
+
+import "math/bits"
+
+// termSet is a set of terminal numbers.
+type termSet []uint64
+
+func newTermSet(n int) termSet { return make(termSet, (n+63)/64) }
+
+func (s termSet) add(t int32) { s[t/64] |= 1 << (t % 64) }
+
+func (s termSet) has(t int32) bool { return s[t/64]&(1<<(t%64)) != 0 }
+
+func (s termSet) clone() termSet { return append(termSet(nil), s...) }
+
+// intersects reports whether two sets have a common terminal.
+func (s termSet) intersects(o termSet) bool {
+	for i, w := range o {
+		if s[i]&w != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// union adds the terminals of another set and reports whether the set has changed.
+func (s termSet) union(o termSet) bool {
+	changed := false
+	for i, w := range o {
+		if s[i]|w != s[i] {
+			s[i] |= w
+			changed = true
+		}
+	}
+	return changed
+}
+
+// each calls a function for the terminals of the set in ascending order.
+func (s termSet) each(fn func(t int32)) {
+	for i, w := range s {
+		for w != 0 {
+			b := bits.TrailingZeros64(w)
+			fn(int32(i*64 + b))
+			w &^= 1 << b
+		}
+	}
+}
+
+// sets are the FIRST and FOLLOW sets of the non-terminals of a grammar. They're computed once
+// per Build. FIRST sets of the suffixes of right-hand sides are cached too, so states that share
+// items don't compute them again. Every suffix's set is computed from the next one's.
+type sets struct {
+	nullable []bool
+	first    []termSet
+	follow   []termSet
+	suffixes [][]suffix // by rule and dot
+}
+
+// suffix is the cached FIRST set of a suffix of a right-hand side.
+type suffix struct {
+	first    termSet
+	nullable bool
+}
+
+// computeSets computes the nullable non-terminals and the FIRST and FOLLOW sets.
+func (a *automaton) computeSets() {
+	n := len(a.nts)
+	a.nullable = make([]bool, n)
+	a.first = make([]termSet, n)
+	a.follow = make([]termSet, n)
+	for i := 0; i < n; i++ {
+		a.first[i] = newTermSet(len(a.terms))
+		a.follow[i] = newTermSet(len(a.terms))
+	}
+	for changed := true; changed; {
+		changed = false
+		for r, rhs := range a.rhs {
+			lhs := a.ruleLhs[r]
+			nullable := true
+			for _, sym := range rhs {
+				if sym >= 0 {
+					if !a.first[lhs].has(sym) {
+						a.first[lhs].add(sym)
+						changed = true
+					}
+					nullable = false
+					break
+				}
+				if a.first[lhs].union(a.first[-sym-1]) {
+					changed = true
+				}
+				if !a.nullable[-sym-1] {
+					nullable = false
+					break
+				}
+			}
+			if nullable && !a.nullable[lhs] {
+				a.nullable[lhs] = true
+				changed = true
+			}
+		}
+	}
+	a.suffixes = make([][]suffix, len(a.rhs))
+	for r, rhs := range a.rhs {
+		sfs := make([]suffix, len(rhs)+1)
+		sfs[len(rhs)] = suffix{newTermSet(len(a.terms)), true}
+		for dot := len(rhs) - 1; dot >= 0; dot-- {
+			sym := rhs[dot]
+			sf := suffix{newTermSet(len(a.terms)), false}
+			if sym >= 0 {
+				sf.first.add(sym)
+			} else {
+				sf.first.union(a.first[-sym-1])
+				if a.nullable[-sym-1] {
+					sf.first.union(sfs[dot+1].first)
+					sf.nullable = sfs[dot+1].nullable
+				}
+			}
+			sfs[dot] = sf
+		}
+		a.suffixes[r] = sfs
+	}
+	for _, r := range a.rulesWithLhs("0") {
+		a.follow[a.ruleLhs[r]].add(a.eofID)
+	}
+	for changed := true; changed; {
+		changed = false
+		for r, rhs := range a.rhs {
+			for dot, sym := range rhs {
+				if sym >= 0 {
+					continue
+				}
+				follow := a.follow[-sym-1]
+				first, nullable := a.firstOf(r, dot+1)
+				if follow.union(first) {
+					changed = true
+				}
+				if nullable && follow.union(a.follow[a.ruleLhs[r]]) {
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// firstOf returns the FIRST set of the suffix of a rule's right-hand side starting at a dot
+// and whether the suffix is nullable.
+func (a *automaton) firstOf(rule, dot int) (termSet, bool) {
+	sf := a.suffixes[rule][dot]
+	return sf.first, sf.nullable
+}
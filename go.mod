@@ -1,5 +1,5 @@
 module github.com/phomola/shred
 
-go 1.17
+go 1.18
 
-require github.com/phomola/rbtree v0.0.2
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,157 @@
+package shred
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"unicode/utf8"
+)
+
+// GoLexer tokenises source with go/scanner, i.e. with the complete lexical grammar of Go
+// including all its operators, imaginary numbers and automatic semicolon insertion.
+// Go's keywords are keyword tokens and operators are matched by their text. Inserted
+// semicolons match ";" and their raw text is the newline they replace or empty at the end.
+// Whitespace isn't kept.
+type GoLexer struct {
+	// KeepComments makes the lexer emit comments as tokens.
+	KeepComments bool
+}
+
+// TokeniseString tokenises a string.
+func (l *GoLexer) TokeniseString(src string) ([]Token, error) {
+	return l.Tokenise([]byte(src))
+}
+
+// Tokenise tokenises source. Lexical errors are returned as a scanner.ErrorList
+// along with the tokens.
+func (l *GoLexer) Tokenise(src []byte) ([]Token, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+	var errs scanner.ErrorList
+	var s scanner.Scanner
+	var mode scanner.Mode
+	if l.KeepComments {
+		mode = scanner.ScanComments
+	}
+	s.Init(file, src, func(pos token.Position, msg string) { errs.Add(pos, msg) }, mode)
+	var tokens []Token
+	line, lineStart := 1, 0
+	for {
+		pos, tok, lit := s.Scan()
+		p := fset.Position(pos)
+		for line < p.Line {
+			lineStart = file.Offset(file.LineStart(line + 1))
+			line++
+		}
+		col := utf8.RuneCount(src[lineStart:p.Offset]) + 1
+		t := &goScanToken{tok, lit, Position{p.Offset, p.Line, col}}
+		tokens = append(tokens, t)
+		if tok == token.EOF {
+			break
+		}
+	}
+	return tokens, errs.Err()
+}
+
+// goScanToken is a token produced by go/scanner.
+type goScanToken struct {
+	tok token.Token
+	lit string
+	pos Position
+}
+
+func (t *goScanToken) String() string {
+	var name string
+	switch {
+	case t.tok.IsKeyword():
+		name = "Keyword"
+	case t.tok == token.IDENT:
+		name = "Ident"
+	case t.tok == token.EOF:
+		name = "EOF"
+	case t.tok.IsLiteral() || t.tok == token.COMMENT:
+		name = t.Kind().String()
+	default:
+		name = strconv.Quote(t.tok.String())
+	}
+	return fmt.Sprintf("%s[%s:%d:%d]", name, t.Text(), t.Line(), t.Column())
+}
+
+func (t *goScanToken) Text() string {
+	switch {
+	case t.tok == token.STRING || t.tok == token.CHAR:
+		return t.lit[1 : len(t.lit)-1]
+	case t.tok == token.SEMICOLON:
+		return ";"
+	case t.lit != "":
+		return t.lit
+	case t.tok == token.EOF:
+		return ""
+	}
+	return t.tok.String()
+}
+
+func (t *goScanToken) Raw() string {
+	if t.lit != "" || t.tok == token.SEMICOLON || t.tok == token.EOF {
+		return t.lit
+	}
+	return t.tok.String()
+}
+
+func (t *goScanToken) Kind() Kind {
+	switch t.tok {
+	case token.IDENT:
+		return KindIdent
+	case token.INT:
+		return KindInt
+	case token.FLOAT, token.IMAG:
+		return KindFloat
+	case token.STRING:
+		if t.lit[0] == '`' {
+			return KindRawString
+		}
+		return KindString
+	case token.CHAR:
+		return KindChar
+	case token.EOF:
+		return KindEOF
+	case token.COMMENT:
+		return KindComment
+	}
+	if t.tok.IsKeyword() {
+		return KindKeyword
+	}
+	return KindOther
+}
+
+func (t *goScanToken) IsEOF() bool { return t.tok == token.EOF }
+
+func (t *goScanToken) IsIdent() bool { return t.tok == token.IDENT }
+
+func (t *goScanToken) IsKeyword() bool { return t.tok.IsKeyword() }
+
+func (t *goScanToken) IsInt() bool { return t.tok == token.INT }
+
+func (t *goScanToken) IsFloat() bool { return t.tok == token.FLOAT || t.tok == token.IMAG }
+
+func (t *goScanToken) IsString() bool { return t.Kind() == KindString }
+
+func (t *goScanToken) IsRawString() bool { return t.Kind() == KindRawString }
+
+func (t *goScanToken) IsChar() bool { return t.tok == token.CHAR }
+
+func (t *goScanToken) IsWhitespace() bool { return false }
+
+func (t *goScanToken) IsComment() bool { return t.tok == token.COMMENT }
+
+func (t *goScanToken) Line() int { return t.pos.Line }
+
+func (t *goScanToken) Column() int { return t.pos.Column }
+
+func (t *goScanToken) Pos() Position { return t.pos }
+
+// MarshalJSON encodes the token as an object with its kind, text and position.
+func (t *goScanToken) MarshalJSON() ([]byte, error) {
+	return marshalJSON(tokenToJSON(t))
+}
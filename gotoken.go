@@ -0,0 +1,39 @@
+package shred
+
+import (
+	"go/token"
+	"unicode/utf8"
+)
+
+// GoFile relates positions in a source to token.Pos values of a file in a token.FileSet,
+// so positions of tokens and nodes can be used with the standard library's position tooling.
+// Note that go/token counts columns in bytes while shred counts them in characters.
+type GoFile struct {
+	File *token.File
+	src  string
+}
+
+// NewGoFile adds a file for a source to a file set.
+func NewGoFile(fset *token.FileSet, filename, src string) *GoFile {
+	f := fset.AddFile(filename, -1, len(src))
+	f.SetLinesForContent([]byte(src))
+	return &GoFile{f, src}
+}
+
+// Pos returns the token.Pos of a position in the source.
+func (f *GoFile) Pos(p Position) token.Pos { return f.File.Pos(p.Offset) }
+
+// TokenPos returns the token.Pos of a token.
+func (f *GoFile) TokenPos(t Token) token.Pos { return f.Pos(t.Pos()) }
+
+// Span returns the token.Pos values of the start and end of a span.
+func (f *GoFile) Span(s Span) (start, end token.Pos) { return f.Pos(s.Start), f.Pos(s.End) }
+
+// Position returns the position in the source of a token.Pos of the file.
+func (f *GoFile) Position(p token.Pos) Position {
+	off := f.File.Offset(p)
+	line := f.File.Line(p)
+	start := f.File.LineStart(line)
+	col := utf8.RuneCountInString(f.src[f.File.Offset(start):off]) + 1
+	return Position{off, line, col}
+}
@@ -0,0 +1,56 @@
+package grammars
+
+import (
+	"errors"
+
+	"github.com/phomola/shred"
+	"github.com/phomola/shred/builders"
+)
+
+var arithGrammar = lazyGrammar{rules: arithRules}
+
+// ErrDivisionByZero is returned by ParseArithmetic when an expression divides by zero.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// Arithmetic returns a grammar of arithmetic expressions for the default tokeniser that
+// evaluates them to float64 values. Expressions have numbers, parentheses, unary minus and
+// the binary operators +, -, * and /, which are left-associative with the usual precedences.
+func Arithmetic() *shred.Grammar { return arithGrammar.get() }
+
+// ParseArithmetic evaluates an arithmetic expression.
+func ParseArithmetic(src string) (float64, error) {
+	v, err := Arithmetic().ParseString(src)
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+func arithRules() []*shred.Rule {
+	binary := func(op func(x, y float64) float64) func([]interface{}) interface{} {
+		return func(args []interface{}) interface{} { return op(args[0].(float64), args[2].(float64)) }
+	}
+	return []*shred.Rule{
+		{Lhs: "0", Rhs: shred.Seq(shred.NT("Sum")), Builder: builders.First},
+		{Lhs: "Sum", Rhs: shred.Seq(shred.NT("Sum"), shred.T("+"), shred.NT("Product")), Builder: binary(func(x, y float64) float64 { return x + y })},
+		{Lhs: "Sum", Rhs: shred.Seq(shred.NT("Sum"), shred.T("-"), shred.NT("Product")), Builder: binary(func(x, y float64) float64 { return x - y })},
+		{Lhs: "Sum", Rhs: shred.Seq(shred.NT("Product")), Builder: builders.First},
+		{Lhs: "Product", Rhs: shred.Seq(shred.NT("Product"), shred.T("*"), shred.NT("Unary")), Builder: binary(func(x, y float64) float64 { return x * y })},
+		{Lhs: "Product", Rhs: shred.Seq(shred.NT("Product"), shred.T("/"), shred.NT("Unary")), BuilderErr: func(args []interface{}) (interface{}, error) {
+			if args[2].(float64) == 0 {
+				return nil, ErrDivisionByZero
+			}
+			return args[0].(float64) / args[2].(float64), nil
+		}},
+		{Lhs: "Product", Rhs: shred.Seq(shred.NT("Unary")), Builder: builders.First},
+		{Lhs: "Unary", Rhs: shred.Seq(shred.T("-"), shred.NT("Unary")), Builder: func(args []interface{}) interface{} {
+			return -args[1].(float64)
+		}},
+		{Lhs: "Unary", Rhs: shred.Seq(shred.NT("Atom")), Builder: builders.First},
+		{Lhs: "Atom", Rhs: shred.Seq(shred.Literal(shred.KindInt)), BuilderErr: parseNumber},
+		{Lhs: "Atom", Rhs: shred.Seq(shred.Literal(shred.KindFloat)), BuilderErr: parseNumber},
+		{Lhs: "Atom", Rhs: shred.Seq(shred.T("("), shred.NT("Sum"), shred.T(")")), Builder: func(args []interface{}) interface{} {
+			return args[1]
+		}},
+	}
+}
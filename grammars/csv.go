@@ -0,0 +1,112 @@
+package grammars
+
+import (
+	"strings"
+
+	"github.com/phomola/shred"
+)
+
+var csvGrammar = lazyGrammar{rules: csvRules}
+
+// CSV returns a grammar of comma-separated values as described in RFC 4180 for the tokens
+// of TokeniseCSV. A source is parsed into records of fields. Records may have different
+// numbers of fields and an empty line is a record with an empty field.
+// The grammar doesn't accept an empty source, which ParseCSV parses as no records.
+func CSV() *shred.Grammar { return csvGrammar.get() }
+
+// ParseCSV parses comma-separated values.
+func ParseCSV(src string) ([][]string, error) {
+	tokens, err := TokeniseCSV(src)
+	if err != nil || tokens[0].IsEOF() {
+		return nil, err
+	}
+	v, err := CSV().Parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return v.([][]string), nil
+}
+
+// TokeniseCSV tokenises comma-separated values. Fields are string tokens whose text is
+// unquoted if they're quoted, commas match ",", and line breaks, either \n or \r\n, match "\n".
+// A line break at the end of the source is dropped. Quoted fields may contain commas,
+// line breaks and doubled quotes.
+func TokeniseCSV(src string) ([]shred.Token, error) {
+	var tokens []shred.Token
+	c := newCursor(src)
+	end := len(src)
+	switch {
+	case strings.HasSuffix(src, "\r\n"):
+		end -= 2
+	case strings.HasSuffix(src, "\n"):
+		end--
+	}
+	for i := 0; i < end; {
+		switch {
+		case src[i] == ',':
+			tokens = append(tokens, c.token(shred.KindOther, ",", i, i+1))
+			i++
+		case src[i] == '\n':
+			tokens = append(tokens, c.token(shred.KindOther, "\n", i, i+1))
+			i++
+		case strings.HasPrefix(src[i:end], "\r\n"):
+			tokens = append(tokens, c.token(shred.KindOther, "\n", i, i+2))
+			i += 2
+		case src[i] == '"':
+			var sb strings.Builder
+			j := i + 1
+			for {
+				k := strings.IndexByte(src[j:end], '"')
+				if k < 0 {
+					c.skipTo(i)
+					return tokens, c.errorf("unterminated quoted field")
+				}
+				sb.WriteString(src[j : j+k])
+				j += k + 1
+				if j == end || src[j] != '"' {
+					break
+				}
+				sb.WriteByte('"')
+				j++
+			}
+			tokens = append(tokens, c.token(shred.KindString, sb.String(), i, j))
+			i = j
+		default:
+			j := i + strings.IndexAny(src[i:end]+"\n", ",\n\"")
+			if j < end && src[j] == '"' {
+				c.skipTo(j)
+				return tokens, c.errorf("quote in unquoted field")
+			}
+			if j > i && src[j-1] == '\r' && j < end {
+				j--
+			}
+			tokens = append(tokens, c.token(shred.KindString, src[i:j], i, j))
+			i = j
+		}
+	}
+	return append(tokens, c.eof()), nil
+}
+
+func csvRules() []*shred.Rule {
+	return []*shred.Rule{
+		{Lhs: "0", Rhs: shred.Seq(shred.NT("Records")), Builder: func(args []interface{}) interface{} {
+			return args[0]
+		}},
+		{Lhs: "Records", Rhs: shred.Seq(shred.NT("Record")), Builder: func(args []interface{}) interface{} {
+			return [][]string{args[0].([]string)}
+		}},
+		{Lhs: "Records", Rhs: shred.Seq(shred.NT("Records"), shred.T("\n"), shred.NT("Record")), Builder: func(args []interface{}) interface{} {
+			return append(args[0].([][]string), args[2].([]string))
+		}},
+		{Lhs: "Record", Rhs: shred.Seq(shred.NT("Field")), Builder: func(args []interface{}) interface{} {
+			return []string{args[0].(string)}
+		}},
+		{Lhs: "Record", Rhs: shred.Seq(shred.NT("Record"), shred.T(","), shred.NT("Field")), Builder: func(args []interface{}) interface{} {
+			return append(args[0].([]string), args[2].(string))
+		}},
+		{Lhs: "Field", Rhs: nil, Builder: func([]interface{}) interface{} { return "" }},
+		{Lhs: "Field", Rhs: shred.Seq(shred.Literal(shred.KindString)), Builder: func(args []interface{}) interface{} {
+			return args[0].(shred.Token).Text()
+		}},
+	}
+}
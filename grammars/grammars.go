@@ -0,0 +1,128 @@
+// Package grammars has ready-made grammars for JSON, arithmetic expressions, INI files and CSV
+// built with shred. Each format has a function returning its grammar, whose builders produce
+// Go values, and a function parsing a string with it. They double as examples of how grammars,
+// builders and custom tokenisers are written.
+package grammars
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/phomola/shred"
+)
+
+// lazyGrammar is a grammar built on first use.
+type lazyGrammar struct {
+	once  sync.Once
+	rules func() []*shred.Rule
+	gr    *shred.Grammar
+}
+
+// get returns the grammar, building it if needed. The grammars are SLR so that they
+// reduce the start symbol only at the end of the input. Their rules have no conflicts.
+func (l *lazyGrammar) get() *shred.Grammar {
+	l.once.Do(func() {
+		l.gr = shred.NewGrammar(l.rules())
+		l.gr.Mode = shred.ModeSLR
+		if err := l.gr.Build(); err != nil {
+			panic(err)
+		}
+	})
+	return l.gr
+}
+
+// token is a token produced by the line-based tokenisers of this package.
+// Text is the decoded text and raw is the text in the source.
+type token struct {
+	kind      shred.Kind
+	text, raw string
+	pos       shred.Position
+}
+
+func (t *token) String() string {
+	return fmt.Sprintf("%s[%s:%d:%d]", t.kind, strconv.Quote(t.text), t.pos.Line, t.pos.Column)
+}
+
+func (t *token) Text() string { return t.text }
+
+func (t *token) Raw() string { return t.raw }
+
+func (t *token) Kind() shred.Kind { return t.kind }
+
+func (t *token) IsEOF() bool { return t.kind == shred.KindEOF }
+
+func (t *token) IsIdent() bool { return t.kind == shred.KindIdent }
+
+func (t *token) IsKeyword() bool { return false }
+
+func (t *token) IsInt() bool { return false }
+
+func (t *token) IsFloat() bool { return false }
+
+func (t *token) IsString() bool { return t.kind == shred.KindString }
+
+func (t *token) IsRawString() bool { return false }
+
+func (t *token) IsChar() bool { return false }
+
+func (t *token) IsWhitespace() bool { return false }
+
+func (t *token) IsComment() bool { return false }
+
+func (t *token) Line() int { return t.pos.Line }
+
+func (t *token) Column() int { return t.pos.Column }
+
+func (t *token) Pos() shred.Position { return t.pos }
+
+// cursor tracks the position in a source while tokenising it.
+type cursor struct {
+	src string
+	pos shred.Position
+}
+
+func newCursor(src string) *cursor {
+	return &cursor{src, shred.Position{Offset: 0, Line: 1, Column: 1}}
+}
+
+// token returns a token of src[start:end] and advances past it.
+func (c *cursor) token(kind shred.Kind, text string, start, end int) shred.Token {
+	c.skipTo(start)
+	t := &token{kind, text, c.src[start:end], c.pos}
+	c.skipTo(end)
+	return t
+}
+
+// skipTo advances to an offset.
+func (c *cursor) skipTo(offset int) {
+	for _, ch := range c.src[c.pos.Offset:offset] {
+		if ch == '\n' {
+			c.pos.Line++
+			c.pos.Column = 1
+		} else {
+			c.pos.Column++
+		}
+	}
+	c.pos.Offset = offset
+}
+
+// trim returns the bounds of src[start:end] without leading and trailing whitespace.
+func (c *cursor) trim(start, end int) (int, int) {
+	s := c.src[start:end]
+	start += len(s) - len(strings.TrimLeftFunc(s, unicode.IsSpace))
+	return start, start + len(strings.TrimSpace(s))
+}
+
+// errorf returns an error at the current position.
+func (c *cursor) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s", c.pos, fmt.Sprintf(format, args...))
+}
+
+// eof returns the EOF token at the end of the source.
+func (c *cursor) eof() shred.Token {
+	c.skipTo(len(c.src))
+	return &token{shred.KindEOF, "", "", c.pos}
+}
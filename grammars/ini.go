@@ -0,0 +1,104 @@
+package grammars
+
+import (
+	"strings"
+
+	"github.com/phomola/shred"
+)
+
+var iniGrammar = lazyGrammar{rules: iniRules}
+
+// INI returns a grammar of INI files for the tokens of TokeniseINI. A file is parsed into
+// a map from section names to maps from keys to values. Keys before the first section are
+// in the section "". Repeated sections are merged and later values of a key replace earlier ones.
+func INI() *shred.Grammar { return iniGrammar.get() }
+
+// ParseINI parses an INI file.
+func ParseINI(src string) (map[string]map[string]string, error) {
+	tokens, err := TokeniseINI(src)
+	if err != nil {
+		return nil, err
+	}
+	v, err := INI().Parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*iniFile).sections, nil
+}
+
+// TokeniseINI tokenises an INI file line by line. A line is a section header [name],
+// a key-value pair key = value, a comment starting with ; or #, or blank. Names, keys and values
+// are trimmed string tokens and the brackets and equals signs are matched by their text.
+func TokeniseINI(src string) ([]shred.Token, error) {
+	var tokens []shred.Token
+	c := newCursor(src)
+	for start := 0; start < len(src); {
+		end := len(src)
+		if i := strings.IndexByte(src[start:], '\n'); i >= 0 {
+			end = start + i
+		}
+		first, last := c.trim(start, end)
+		c.skipTo(first)
+		switch line := src[first:last]; {
+		case line == "" || line[0] == ';' || line[0] == '#':
+		case line[0] == '[':
+			if line[len(line)-1] != ']' {
+				return tokens, c.errorf("malformed section header")
+			}
+			nameStart, nameEnd := c.trim(first+1, last-1)
+			tokens = append(tokens,
+				c.token(shred.KindOther, "[", first, first+1),
+				c.token(shred.KindString, src[nameStart:nameEnd], nameStart, nameEnd),
+				c.token(shred.KindOther, "]", last-1, last))
+		default:
+			eq := strings.IndexByte(line, '=')
+			if eq <= 0 {
+				return tokens, c.errorf("expected a section header or a key-value pair")
+			}
+			eq += first
+			keyStart, keyEnd := c.trim(first, eq)
+			valueStart, valueEnd := c.trim(eq+1, last)
+			tokens = append(tokens,
+				c.token(shred.KindString, src[keyStart:keyEnd], keyStart, keyEnd),
+				c.token(shred.KindOther, "=", eq, eq+1),
+				c.token(shred.KindString, src[valueStart:valueEnd], valueStart, valueEnd))
+		}
+		start = end + 1
+	}
+	return append(tokens, c.eof()), nil
+}
+
+// iniFile is the value of a file being parsed.
+type iniFile struct {
+	sections map[string]map[string]string
+	current  string
+}
+
+func iniRules() []*shred.Rule {
+	str := shred.Literal(shred.KindString)
+	text := func(arg interface{}) string { return arg.(shred.Token).Text() }
+	return []*shred.Rule{
+		{Lhs: "0", Rhs: shred.Seq(shred.NT("File")), Builder: func(args []interface{}) interface{} {
+			return args[0]
+		}},
+		{Lhs: "File", Rhs: nil, Builder: func([]interface{}) interface{} {
+			return &iniFile{sections: make(map[string]map[string]string)}
+		}},
+		{Lhs: "File", Rhs: shred.Seq(shred.NT("File"), shred.T("["), str, shred.T("]")), Builder: func(args []interface{}) interface{} {
+			f := args[0].(*iniFile)
+			f.current = text(args[2])
+			if f.sections[f.current] == nil {
+				f.sections[f.current] = make(map[string]string)
+			}
+			return f
+		}},
+		{Lhs: "File", Rhs: shred.Seq(shred.NT("File"), str, shred.T("="), str), Builder: func(args []interface{}) interface{} {
+			f := args[0].(*iniFile)
+			if f.sections[f.current] == nil {
+				f.sections[f.current] = make(map[string]string)
+			}
+			f.sections[f.current][text(args[1])] = text(args[3])
+			return f
+		}},
+	}
+}
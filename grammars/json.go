@@ -0,0 +1,67 @@
+package grammars
+
+import (
+	"strconv"
+
+	"github.com/phomola/shred"
+	"github.com/phomola/shred/builders"
+)
+
+var jsonGrammar = lazyGrammar{rules: jsonRules}
+
+// JSON returns a grammar of JSON for the default tokeniser. Values are decoded as by
+// encoding/json into an interface{}: objects are map[string]interface{}, arrays are
+// []interface{}, numbers are float64, and null is nil.
+func JSON() *shred.Grammar { return jsonGrammar.get() }
+
+// ParseJSON parses a JSON value.
+func ParseJSON(src string) (interface{}, error) {
+	return JSON().ParseString(src)
+}
+
+func jsonRules() []*shred.Rule {
+	str, num := shred.Literal(shred.KindString), shred.Literal(shred.KindFloat)
+	integer := shred.Literal(shred.KindInt)
+	return []*shred.Rule{
+		{Lhs: "0", Rhs: shred.Seq(shred.NT("Value")), Builder: builders.First},
+		{Lhs: "Value", Rhs: shred.Seq(shred.NT("Object")), Builder: builders.First},
+		{Lhs: "Value", Rhs: shred.Seq(shred.NT("Array")), Builder: builders.First},
+		{Lhs: "Value", Rhs: shred.Seq(shred.NT("String")), Builder: builders.First},
+		{Lhs: "Value", Rhs: shred.Seq(shred.NT("Number")), Builder: builders.First},
+		{Lhs: "Value", Rhs: shred.Seq(shred.T("true")), Builder: builders.Const(true)},
+		{Lhs: "Value", Rhs: shred.Seq(shred.T("false")), Builder: builders.Const(false)},
+		{Lhs: "Value", Rhs: shred.Seq(shred.T("null")), Builder: builders.Const(nil)},
+		{Lhs: "Object", Rhs: shred.Seq(shred.T("{"), shred.T("}")), Builder: func([]interface{}) interface{} {
+			return make(map[string]interface{})
+		}},
+		{Lhs: "Object", Rhs: shred.Seq(shred.T("{"), shred.NT("Members"), shred.T("}")), Builder: builders.PickNth(1)},
+		{Lhs: "Members", Rhs: shred.Seq(shred.NT("String"), shred.T(":"), shred.NT("Value")), Builder: func(args []interface{}) interface{} {
+			return map[string]interface{}{args[0].(string): args[2]}
+		}},
+		{Lhs: "Members", Rhs: shred.Seq(shred.NT("Members"), shred.T(","), shred.NT("String"), shred.T(":"), shred.NT("Value")), Builder: func(args []interface{}) interface{} {
+			m := args[0].(map[string]interface{})
+			m[args[2].(string)] = args[4]
+			return m
+		}},
+		{Lhs: "Array", Rhs: shred.Seq(shred.T("["), shred.T("]")), Builder: func([]interface{}) interface{} {
+			return []interface{}{}
+		}},
+		{Lhs: "Array", Rhs: shred.Seq(shred.T("["), shred.NT("Elements"), shred.T("]")), Builder: builders.PickNth(1)},
+		{Lhs: "Elements", Rhs: shred.Seq(shred.NT("Value")), Builder: builders.WrapSlice},
+		{Lhs: "Elements", Rhs: shred.Seq(shred.NT("Elements"), shred.T(","), shred.NT("Value")), Builder: builders.Append(0, 2)},
+		{Lhs: "String", Rhs: shred.Seq(str), BuilderErr: func(args []interface{}) (interface{}, error) {
+			return shred.JSONEscapes.Unquote(args[0].(shred.Token))
+		}},
+		{Lhs: "Number", Rhs: shred.Seq(shred.NT("Unsigned")), Builder: builders.First},
+		{Lhs: "Number", Rhs: shred.Seq(shred.T("-"), shred.NT("Unsigned")), Builder: func(args []interface{}) interface{} {
+			return -args[1].(float64)
+		}},
+		{Lhs: "Unsigned", Rhs: shred.Seq(integer), BuilderErr: parseNumber},
+		{Lhs: "Unsigned", Rhs: shred.Seq(num), BuilderErr: parseNumber},
+	}
+}
+
+// parseNumber parses the number token of a rule's right-hand side.
+func parseNumber(args []interface{}) (interface{}, error) {
+	return strconv.ParseFloat(args[0].(shred.Token).Text(), 64)
+}
@@ -0,0 +1,94 @@
+package shred
+
+import "unicode"
+
+// Class is a token's syntactic category used for highlighting.
+type Class byte
+
+const (
+	ClassOther Class = iota
+	ClassKeyword
+	ClassIdent
+	ClassLiteral
+	ClassOperator
+	ClassComment
+)
+
+// String returns the name of the class.
+func (c Class) String() string {
+	switch c {
+	case ClassKeyword:
+		return "keyword"
+	case ClassIdent:
+		return "ident"
+	case ClassLiteral:
+		return "literal"
+	case ClassOperator:
+		return "operator"
+	case ClassComment:
+		return "comment"
+	default:
+		return "other"
+	}
+}
+
+// Highlight is a token with its class.
+type Highlight struct {
+	Token Token
+	Class Class
+}
+
+// Classify classifies tokens for syntax highlighting.
+// If a grammar is given, identifiers it matches literally are classified as keywords.
+func Classify(tokens []Token, gr *Grammar) []Highlight {
+	var keywords map[string]struct{}
+	if gr != nil {
+		keywords = gr.keywords()
+	}
+	ret := make([]Highlight, len(tokens))
+	for i, tok := range tokens {
+		ret[i] = Highlight{tok, classOf(tok, keywords)}
+	}
+	return ret
+}
+
+func classOf(tok Token, keywords map[string]struct{}) Class {
+	switch tok.Kind() {
+	case KindIdent:
+		if _, ok := keywords[tok.Text()]; ok {
+			return ClassKeyword
+		}
+		return ClassIdent
+	case KindKeyword:
+		return ClassKeyword
+	case KindInt, KindFloat, KindString, KindRawString, KindChar:
+		return ClassLiteral
+	case KindOther:
+		return ClassOperator
+	case KindComment:
+		return ClassComment
+	}
+	return ClassOther
+}
+
+// keywords returns the texts of all identifier-like match terminals.
+func (gr *Grammar) keywords() map[string]struct{} {
+	m := make(map[string]struct{})
+	for _, r := range gr.Rules {
+		for _, s := range r.Rhs {
+			if s, ok := s.(Match); ok && isIdentText(s.Text) {
+				m[s.Text] = struct{}{}
+			}
+		}
+	}
+	return m
+}
+
+func isIdentText(s string) bool {
+	for i, c := range s {
+		if !(c == '_' || unicode.IsLetter(c) || i > 0 && unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return s != ""
+}
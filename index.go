@@ -0,0 +1,128 @@
+package shred
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ColumnUnit is the unit in which columns are counted.
+type ColumnUnit byte
+
+const (
+	UnitUTF16 ColumnUnit = iota // UTF-16 code units, the LSP default
+	UnitRunes                   // Unicode code points, as in Positions
+	UnitBytes                   // UTF-8 bytes, as in go/token
+)
+
+// width returns the width of a character in the unit.
+func (u ColumnUnit) width(c rune, size int) int {
+	switch u {
+	case UnitRunes:
+		return 1
+	case UnitBytes:
+		return size
+	}
+	if c >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// SourceIndex converts between byte offsets in a source and lines and columns, which count
+// from 1 as in Positions. Columns are counted in a ColumnUnit. Offsets out of the source
+// are clamped to it.
+type SourceIndex struct {
+	src   string
+	lines []int // offsets of the starts of lines
+}
+
+// NewSourceIndex indexes the lines of a source.
+func NewSourceIndex(src string) *SourceIndex {
+	x := &SourceIndex{src: src, lines: []int{0}}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			x.lines = append(x.lines, i+1)
+		}
+	}
+	return x
+}
+
+// Source returns the indexed source.
+func (x *SourceIndex) Source() string { return x.src }
+
+// Lines returns the number of lines of the source. A line break ending the source
+// is followed by an empty line.
+func (x *SourceIndex) Lines() int { return len(x.lines) }
+
+// LineText returns the n-th line without its line terminator or false if there's no such line.
+func (x *SourceIndex) LineText(n int) (string, bool) {
+	if n < 1 || n > len(x.lines) {
+		return "", false
+	}
+	end := len(x.src)
+	if n < len(x.lines) {
+		end = x.lines[n]
+	}
+	return strings.TrimRight(x.src[x.lines[n-1]:end], "\r\n"), true
+}
+
+func (x *SourceIndex) clamp(offset int) int {
+	switch {
+	case offset < 0:
+		return 0
+	case offset > len(x.src):
+		return len(x.src)
+	}
+	return offset
+}
+
+// Line returns the line of an offset.
+func (x *SourceIndex) Line(offset int) int {
+	return sort.SearchInts(x.lines, x.clamp(offset)+1)
+}
+
+// Column returns the column of an offset in a unit.
+func (x *SourceIndex) Column(offset int, unit ColumnUnit) int {
+	offset = x.clamp(offset)
+	col := 1
+	for i := x.lines[x.Line(offset)-1]; i < offset; {
+		c, size := utf8.DecodeRuneInString(x.src[i:offset])
+		col += unit.width(c, size)
+		i += size
+	}
+	return col
+}
+
+// Position returns the position of an offset.
+func (x *SourceIndex) Position(offset int) Position {
+	offset = x.clamp(offset)
+	return Position{offset, x.Line(offset), x.Column(offset, UnitRunes)}
+}
+
+// Span returns the span between two offsets.
+func (x *SourceIndex) Span(start, end int) Span { return Span{x.Position(start), x.Position(end)} }
+
+// Offset returns the offset of a line and a column in a unit. Lines before the first one
+// are at the start of the source and lines after the last one at its end. Columns past
+// the end of a line are at its end and columns within a character at its start.
+func (x *SourceIndex) Offset(line, column int, unit ColumnUnit) int {
+	switch {
+	case line < 1:
+		return 0
+	case line > len(x.lines):
+		return len(x.src)
+	}
+	i, end := x.lines[line-1], len(x.src)
+	if line < len(x.lines) {
+		end = x.lines[line] - 1
+	}
+	for col := 1; i < end; {
+		c, size := utf8.DecodeRuneInString(x.src[i:end])
+		if col += unit.width(c, size); col > column {
+			break
+		}
+		i += size
+	}
+	return i
+}
@@ -0,0 +1,102 @@
+package shred
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+type tokenJSON struct {
+	Kind string   `json:"kind"`
+	Text string   `json:"text"`
+	Raw  string   `json:"raw"`
+	Pos  Position `json:"pos"`
+}
+
+type nodeJSON struct {
+	Lhs      string        `json:"lhs"`
+	Rule     string        `json:"rule"`
+	Span     Span          `json:"span"`
+	Children []interface{} `json:"children"`
+}
+
+type errorNodeJSON struct {
+	Error  string      `json:"error"`
+	Span   Span        `json:"span"`
+	Tokens []tokenJSON `json:"tokens"`
+}
+
+func errorNodeToJSON(n *ErrorNode) errorNodeJSON {
+	ret := errorNodeJSON{Span: n.span, Tokens: make([]tokenJSON, len(n.tokens))}
+	if n.err != nil {
+		ret.Error = n.err.Error()
+	}
+	for i, t := range n.tokens {
+		ret.Tokens[i] = tokenToJSON(t)
+	}
+	return ret
+}
+
+func tokenToJSON(t Token) tokenJSON {
+	return tokenJSON{t.Kind().String(), t.Text(), t.Raw(), t.Pos()}
+}
+
+// MarshalJSON encodes the token as an object with its kind, text and position.
+func (t *goToken) MarshalJSON() ([]byte, error) {
+	return marshalJSON(tokenToJSON(t))
+}
+
+// MarshalJSON encodes the node as an object with its rule, span and children.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	return marshalJSON(nodeJSON{n.rule.Lhs, n.rule.String(), n.span, jsonValues(n.children)})
+}
+
+// MarshalJSON encodes the error node as an object with its error, span and tokens.
+func (n *ErrorNode) MarshalJSON() ([]byte, error) {
+	return marshalJSON(errorNodeToJSON(n))
+}
+
+// MarshalResult encodes a parse result as JSON.
+// Tokens, Nodes, ErrorNodes and slices of values are encoded even if they're nested in values
+// that aren't JSON-aware, other values are encoded by encoding/json.
+func MarshalResult(v interface{}) ([]byte, error) {
+	return marshalJSON(jsonValue(v))
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *Node:
+		return nodeJSON{v.rule.Lhs, v.rule.String(), v.span, jsonValues(v.children)}
+	case *ErrorNode:
+		return errorNodeToJSON(v)
+	case Token:
+		return tokenToJSON(v)
+	case []interface{}:
+		return jsonValues(v)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			m[k] = jsonValue(x)
+		}
+		return m
+	}
+	return v
+}
+
+func jsonValues(vs []interface{}) []interface{} {
+	ret := make([]interface{}, len(vs))
+	for i, v := range vs {
+		ret[i] = jsonValue(v)
+	}
+	return ret
+}
+
+// marshalJSON encodes a value without escaping HTML characters.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,139 @@
+package shred
+
+import (
+	"context"
+	"io"
+)
+
+// Language is a compiled grammar returned by Compile. It's immutable: it owns copies of the rules
+// and settings of the grammar together with the automaton built from them, so the grammar can be
+// changed and compiled again while the language is in use. A language is safe for concurrent use.
+type Language struct {
+	gr *Grammar // built copy that is never modified
+}
+
+// Compile builds a language from the current rules, settings and reduce hooks of the grammar.
+// The rules are copied while their builders and tags are shared. The grammar itself isn't built.
+// If there are conflicts, the language is returned with a ConflictError as by Build.
+func (gr *Grammar) Compile() (*Language, error) {
+	_, hooks := gr.current()
+	rules := make([]*Rule, len(gr.Rules))
+	for i, r := range gr.Rules {
+		c := *r
+		c.Rhs = append([]Symbol(nil), r.Rhs...)
+		rules[i] = &c
+	}
+	var holes map[string]string
+	if gr.Placeholders != nil {
+		holes = make(map[string]string, len(gr.Placeholders))
+		for name, nt := range gr.Placeholders {
+			holes[name] = nt
+		}
+	}
+	g := &Grammar{
+		Rules:        rules,
+		Reduce:       gr.Reduce,
+		Hooks:        gr.Hooks,
+		Limits:       gr.Limits,
+		Mode:         gr.Mode,
+		Workers:      gr.Workers,
+		Engine:       gr.Engine,
+		Placeholders: holes,
+		Tokeniser:    gr.Tokeniser,
+		Preprocess:   gr.Preprocess,
+		Scopes:       gr.Scopes,
+		onReduce:     hooks,
+	}
+	err := g.Build()
+	return &Language{g}, err
+}
+
+// Rules returns the rules of the language, which must not be modified.
+func (l *Language) Rules() []*Rule { return append([]*Rule(nil), l.gr.Rules...) }
+
+// Mode returns the mode the language was built in.
+func (l *Language) Mode() Mode { return l.gr.Mode }
+
+// Conflicts returns the conflicts of the language.
+func (l *Language) Conflicts() []Conflict { return l.gr.Conflicts() }
+
+// Parse parses a sequence of tokens.
+func (l *Language) Parse(tokens []Token) (interface{}, error) { return l.gr.Parse(tokens) }
+
+// MustParse parses a sequence of tokens and panics if the parse fails.
+func (l *Language) MustParse(tokens []Token) interface{} { return l.gr.MustParse(tokens) }
+
+// ParseString tokenises a string with the tokeniser of the grammar and parses it.
+func (l *Language) ParseString(s string) (interface{}, error) { return l.gr.ParseString(s) }
+
+// ParseReader tokenises the contents of a reader with the tokeniser of the grammar and parses them.
+func (l *Language) ParseReader(r io.Reader) (interface{}, error) { return l.gr.ParseReader(r) }
+
+// ParseConcurrent tokenises the contents of a reader in another goroutine while it parses them.
+func (l *Language) ParseConcurrent(r io.Reader) (interface{}, error) { return l.gr.ParseConcurrent(r) }
+
+// ParsePrefix parses a sequence of tokens and returns the index of the token where it stopped.
+func (l *Language) ParsePrefix(tokens []Token) (interface{}, int, error) {
+	return l.gr.ParsePrefix(tokens)
+}
+
+// ParseItem parses one item at the beginning of a sequence of tokens and returns the remaining tokens.
+func (l *Language) ParseItem(tokens []Token) (interface{}, []Token, error) {
+	return l.gr.ParseItem(tokens)
+}
+
+// ParseEnv parses a sequence of tokens passing an environment to Reduce builders.
+func (l *Language) ParseEnv(env interface{}, tokens []Token) (interface{}, error) {
+	return l.gr.ParseEnv(env, tokens)
+}
+
+// ParseContext parses a sequence of tokens until the context is done.
+func (l *Language) ParseContext(ctx context.Context, tokens []Token) (interface{}, error) {
+	return l.gr.ParseContext(ctx, tokens)
+}
+
+// ParseTrace parses a sequence of tokens reporting every step to a tracer.
+func (l *Language) ParseTrace(tokens []Token, tr Tracer) (interface{}, error) {
+	return l.gr.ParseTrace(tokens, tr)
+}
+
+// ParseArena parses a sequence of tokens allocating the nodes of rules without builders in an arena.
+func (l *Language) ParseArena(ar *Arena, tokens []Token) (interface{}, error) {
+	return l.gr.ParseArena(ar, tokens)
+}
+
+// ParseMapped parses a sequence of tokens and returns the result with a source map of its values.
+func (l *Language) ParseMapped(tokens []Token) (interface{}, *SourceMap, error) {
+	return l.gr.ParseMapped(tokens)
+}
+
+// ParseMemo parses a sequence of tokens sharing the values of identical subtrees through a memo.
+func (l *Language) ParseMemo(m *Memo, tokens []Token) (interface{}, error) {
+	return l.gr.ParseMemo(m, tokens)
+}
+
+// ParseEvents parses a sequence of tokens reporting the parse to a handler without building any values.
+func (l *Language) ParseEvents(tokens []Token, h EventHandler) error {
+	return l.gr.ParseEvents(tokens, h)
+}
+
+// NewParser creates a parser for a sequence of tokens.
+func (l *Language) NewParser(tokens []Token) *Parser { return l.gr.NewParser(tokens) }
+
+// Explain parses a sequence of tokens and explains why the parse failed.
+func (l *Language) Explain(tokens []Token) string { return l.gr.Explain(tokens) }
+
+// Fingerprint returns a hash of the rules and the mode of the language.
+func (l *Language) Fingerprint() string { return l.gr.Fingerprint() }
+
+// Stats returns statistics of the language.
+func (l *Language) Stats() Stats { return l.gr.Stats() }
+
+// SaveTables writes the automaton of the language so that it can be loaded by LoadTables.
+func (l *Language) SaveTables(w io.Writer) error { return l.gr.SaveTables(w) }
+
+// WriteGo writes a standalone parser for the language as a Go source file of the given package.
+func (l *Language) WriteGo(w io.Writer, pkg string) error { return l.gr.WriteGo(w, pkg) }
+
+// WriteReport writes a human-readable description of the automaton of the language.
+func (l *Language) WriteReport(w io.Writer) error { return l.gr.WriteReport(w) }
@@ -0,0 +1,67 @@
+package shred
+
+import "fmt"
+
+// Limits caps the resources used by a single parse, for parsing untrusted input.
+// Zero values mean no limit. Parses exceeding a limit fail with an error wrapping ErrLimit.
+type Limits struct {
+	MaxDepth      int // maximum number of values on the parse stack
+	MaxReductions int // maximum number of reductions, i.e. of built values
+}
+
+// check returns an error if a parse with a stack of the given depth after the given number
+// of reductions exceeds the limits.
+func (l Limits) check(depth, reductions int) error {
+	switch {
+	case l.MaxDepth > 0 && depth > l.MaxDepth:
+		return newError(ErrLimit, fmt.Sprintf("parse stack exceeds %d values", l.MaxDepth))
+	case l.MaxReductions > 0 && reductions > l.MaxReductions:
+		return newError(ErrLimit, fmt.Sprintf("parse exceeds %d reductions", l.MaxReductions))
+	}
+	return nil
+}
+
+const (
+	arenaNodes  = 256  // nodes per block of an arena
+	arenaValues = 1024 // children per block of an arena
+)
+
+// Arena allocates generic nodes in blocks, so that building a tree takes a few large allocations
+// and its memory is released at once when the tree is dropped. The zero value is an empty arena.
+// An arena must not be used by concurrent parses.
+type Arena struct {
+	nodes  []Node
+	values []interface{}
+}
+
+// newNode creates a node in the arena, or on the heap if the arena is nil.
+func (ar *Arena) newNode(r *Rule, args []interface{}, tokens []Token, first, last int) *Node {
+	if ar == nil {
+		return newNode(r, args, tokens, first, last)
+	}
+	if len(ar.nodes) == cap(ar.nodes) {
+		ar.nodes = make([]Node, 0, arenaNodes)
+	}
+	if cap(ar.values)-len(ar.values) < len(args) {
+		n := arenaValues
+		if len(args) > n {
+			n = len(args)
+		}
+		ar.values = make([]interface{}, 0, n)
+	}
+	n := len(ar.values)
+	ar.values = append(ar.values, args...)
+	ar.nodes = append(ar.nodes, Node{r, ar.values[n:len(ar.values):len(ar.values)], tokens[first:last], spanOf(tokens, first, last), nil})
+	return &ar.nodes[len(ar.nodes)-1]
+}
+
+// Release detaches the arena from the blocks it has allocated. Nodes allocated so far stay valid
+// and their blocks are freed together once none of them is referenced.
+func (ar *Arena) Release() { ar.nodes, ar.values = nil, nil }
+
+// ParseArena parses a sequence of tokens allocating the nodes of rules without builders in an arena.
+func (gr *Grammar) ParseArena(ar *Arena, tokens []Token) (interface{}, error) {
+	b := gr.values(nil)
+	b.arena = ar
+	return gr.parseValues(tokens, b, nil)
+}
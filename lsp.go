@@ -0,0 +1,156 @@
+package shred
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// LSPPosition is a zero-based position in a document as used by the Language Server Protocol.
+type LSPPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// LSPRange is a range in a document as used by the Language Server Protocol.
+type LSPRange struct {
+	Start LSPPosition `json:"start"`
+	End   LSPPosition `json:"end"`
+}
+
+// TextEdit is a change of a document as sent in textDocument/didChange notifications.
+// A nil range replaces the whole text.
+type TextEdit struct {
+	Range *LSPRange `json:"range,omitempty"`
+	Text  string    `json:"text"`
+}
+
+// Document is a versioned text document edited by a client.
+type Document struct {
+	URI     string
+	Version int
+	Unit    ColumnUnit // unit of the columns of LSP positions
+	index   *SourceIndex
+}
+
+// NewDocument creates a document with its URI, version and text. Columns are in UTF-16 code units.
+func NewDocument(uri string, version int, text string) *Document {
+	d := &Document{URI: uri, Version: version}
+	d.setText(text)
+	return d
+}
+
+// Text returns the text of the document.
+func (d *Document) Text() string { return d.index.src }
+
+// Index returns the index of the current text of the document.
+func (d *Document) Index() *SourceIndex { return d.index }
+
+func (d *Document) setText(text string) { d.index = NewSourceIndex(text) }
+
+// LSPPosition converts a byte offset to an LSP position.
+func (d *Document) LSPPosition(offset int) LSPPosition {
+	return LSPPosition{d.index.Line(offset) - 1, d.index.Column(offset, d.Unit) - 1}
+}
+
+// Offset converts an LSP position to a byte offset. Positions past the end of a line
+// are at its end.
+func (d *Document) Offset(p LSPPosition) int {
+	return d.index.Offset(p.Line+1, p.Character+1, d.Unit)
+}
+
+// Range converts a span to an LSP range.
+func (d *Document) Range(s Span) LSPRange {
+	return LSPRange{d.LSPPosition(s.Start.Offset), d.LSPPosition(s.End.Offset)}
+}
+
+// Apply applies edits in order and sets the version of the document.
+func (d *Document) Apply(version int, edits ...TextEdit) error {
+	for _, e := range edits {
+		if e.Range == nil {
+			d.setText(e.Text)
+			continue
+		}
+		start, end := d.Offset(e.Range.Start), d.Offset(e.Range.End)
+		if end < start {
+			return newError(ErrInvalidEdit, "edit range ends before it starts")
+		}
+		d.setText(d.Text()[:start] + e.Text + d.Text()[end:])
+	}
+	d.Version = version
+	return nil
+}
+
+// Diagnostic is a problem in a document reported to an LSP client.
+type Diagnostic struct {
+	Range    LSPRange
+	Severity Severity
+	Code     Code
+	Message  string
+}
+
+// MarshalJSON encodes the diagnostic as an LSP Diagnostic with the source shred.
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	severity := 1
+	if d.Severity == SeverityWarning {
+		severity = 2
+	}
+	return json.Marshal(struct {
+		Range    LSPRange `json:"range"`
+		Severity int      `json:"severity"`
+		Code     Code     `json:"code,omitempty"`
+		Source   string   `json:"source"`
+		Message  string   `json:"message"`
+	}{d.Range, severity, d.Code, "shred", d.Message})
+}
+
+// Diagnostic converts an error returned by a parse to a diagnostic spanning the offending token.
+func (d *Document) Diagnostic(err error) Diagnostic {
+	diag := Diagnostic{Severity: SeverityError, Code: ErrorCode(err), Message: err.Error()}
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		diag.Message = perr.Err.Error()
+		end := perr.Pos
+		if perr.Token != nil && !perr.Token.IsEOF() {
+			raw := perr.Token.Raw()
+			if i := strings.IndexByte(raw, '\n'); i >= 0 {
+				raw = raw[:i]
+			}
+			end = end.advance(raw)
+		}
+		diag.Range = d.Range(Span{perr.Pos, end})
+	}
+	return diag
+}
+
+// ParseResult is the result of parsing a version of a document.
+type ParseResult struct {
+	URI         string
+	Version     int
+	Value       interface{} // result of the parse or nil
+	Err         error
+	Diagnostics []Diagnostic
+}
+
+// ParseDocument parses the current version of a document with a tokeniser, which may be nil
+// for the default one, and returns the result tagged with the version.
+func (gr *Grammar) ParseDocument(tz *Tokeniser, d *Document) *ParseResult {
+	if tz == nil {
+		tz = new(Tokeniser)
+	}
+	v, err := gr.Parse(tz.TokeniseString(d.Text()))
+	res := &ParseResult{URI: d.URI, Version: d.Version, Value: v, Err: err, Diagnostics: []Diagnostic{}}
+	if err != nil {
+		res.Diagnostics = append(res.Diagnostics, d.Diagnostic(err))
+	}
+	return res
+}
+
+// Reparse applies edits to a document, as received in a didChange notification, and parses
+// its new version. The document is parsed again as a whole.
+func (gr *Grammar) Reparse(tz *Tokeniser, d *Document, version int, edits ...TextEdit) (*ParseResult, error) {
+	if err := d.Apply(version, edits...); err != nil {
+		return nil, err
+	}
+	return gr.ParseDocument(tz, d), nil
+}
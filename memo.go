@@ -0,0 +1,164 @@
+package shred
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// Memo shares the values built for identical subtrees within a parse and across parses,
+// which saves memory for repetitive inputs such as generated code or large data files,
+// and spares the builders of subtrees that are already in the memo when a document is parsed
+// again after an edit. Subtrees are identical if they're reduced by the same rule from
+// tokens of the same kinds and texts and values that are identical, which they are for
+// shared values, so the values built for the subtrees must only depend on them:
+// only the values of rules with a Builder or BuilderErr are shared and not those of rules
+// with Scopes actions or values that record their spans, Positioned ones, as the position
+// of a subtree isn't part of it. Shared values must not be modified; maps aren't shared
+// and appending to shared slices is safe, so builders may add to the maps and slices
+// they're passed.
+// A memo is safe for concurrent use and keeps the values, which also keeps the addresses
+// of those that are identified by them from being reused, until it's reset.
+type Memo struct {
+	mu     sync.Mutex
+	ids    map[interface{}]uint64 // identities of rules and children
+	values map[string]interface{} // values by rules and children
+	hits   int
+}
+
+// NewMemo creates an empty memo.
+func NewMemo() *Memo {
+	return &Memo{ids: make(map[interface{}]uint64), values: make(map[string]interface{})}
+}
+
+// Len returns the number of values in the memo.
+func (m *Memo) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.values)
+}
+
+// Hits returns the number of reductions that got a value from the memo.
+func (m *Memo) Hits() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hits
+}
+
+// Reset empties the memo.
+func (m *Memo) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ids, m.values, m.hits = make(map[interface{}]uint64), make(map[string]interface{}), 0
+}
+
+// tokenKey identifies tokens by their kinds and texts.
+type tokenKey struct {
+	kind Kind
+	text string
+}
+
+// id returns the identity of a rule or a child or false if it can't be identified.
+// Tokens are identified by their kinds and texts and values of basic types by equality.
+// Other values are identified if they're stored in the memo: values that aren't
+// were built anew and no subtree with them is identical to another.
+// The memo must be locked.
+func (m *Memo) id(v interface{}) (uint64, bool) {
+	var key interface{}
+	switch v := v.(type) {
+	case nil:
+		return 0, true
+	case *Rule:
+		key = v
+	case Token:
+		key = tokenKey{v.Kind(), v.Text()}
+	default:
+		rv := reflect.ValueOf(v)
+		switch {
+		case isBasicKind(rv.Kind()):
+			key = v
+		case rv.Kind() == reflect.Slice && rv.Len() == 0:
+			key = sliceKey{rv.Type(), 0, 0}
+		default:
+			k, ok := sourceKey(v)
+			if !ok {
+				return 0, false
+			}
+			id, ok := m.ids[k]
+			return id, ok
+		}
+	}
+	return m.identify(key), true
+}
+
+// identify returns the identity of a key, which is new if the key is.
+func (m *Memo) identify(key interface{}) uint64 {
+	id, ok := m.ids[key]
+	if !ok {
+		id = uint64(len(m.ids)) + 1
+		m.ids[key] = id
+	}
+	return id
+}
+
+// key returns the key of a reduction or false if its value isn't shared.
+// The memo must be locked.
+func (m *Memo) key(r *Rule, args []interface{}) (string, bool) {
+	if r.Reduce != nil || r.Builder == nil && r.BuilderErr == nil {
+		return "", false
+	}
+	id, _ := m.id(r)
+	buf := strconv.AppendUint(make([]byte, 0, 4*(len(args)+1)), id, 36)
+	for _, arg := range args {
+		id, ok := m.id(arg)
+		if !ok {
+			return "", false
+		}
+		buf = strconv.AppendUint(append(buf, ','), id, 36)
+	}
+	return string(buf), true
+}
+
+// lookup returns the key of a reduction and the value in the memo for it if there's one.
+func (m *Memo) lookup(r *Rule, args []interface{}) (string, interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.key(r, args)
+	if !ok {
+		return "", nil, false
+	}
+	v, ok := m.values[key]
+	if ok {
+		m.hits++
+	}
+	return key, v, ok
+}
+
+// store stores the value of a reduction unless it records its span or it's a map, which
+// builders commonly add to. Slices are stored with their capacities clipped so that appending
+// to them, as builders of lists do, doesn't overwrite the elements appended by the reduction
+// that built them.
+func (m *Memo) store(key string, v interface{}) {
+	if _, ok := v.(Positioned); ok {
+		return
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Map:
+		return
+	case reflect.Slice:
+		v = rv.Slice3(0, rv.Len(), rv.Len()).Interface()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = v
+	if k, ok := sourceKey(v); ok {
+		m.identify(k)
+	}
+}
+
+// ParseMemo parses a sequence of tokens sharing the values of identical subtrees through a memo.
+func (gr *Grammar) ParseMemo(m *Memo, tokens []Token) (interface{}, error) {
+	vb := gr.values(nil)
+	vb.memo = m
+	return gr.parseValues(tokens, vb, nil)
+}
@@ -1,12 +1,17 @@
 package shred
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
-
-	"github.com/phomola/rbtree"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Symbol is a terminal or non-terminal.
@@ -42,12 +47,20 @@ func (s Ident) String() string { return "_ident_" }
 
 func (s Ident) Kind() Kind { return KindIdent }
 
+// A literal terminal matching the tokens of a kind: KindInt, KindFloat, KindString,
+// KindRawString or KindChar, e.g. Literal(KindInt).
+type Literal Kind
+
+func (s Literal) String() string { return "_" + Kind(s).String() + "_" }
+
+func (s Literal) Kind() Kind { return Kind(s) }
+
 // A match terminal.
 type Match struct {
 	Text string
 }
 
-func (s Match) String() string { return fmt.Sprintf(`"%s"`, s.Text) }
+func (s Match) String() string { return strconv.Quote(s.Text) }
 
 func (s Match) Kind() Kind { return KindMatch }
 
@@ -55,6 +68,8 @@ func terminalFromToken(tok Token) (Terminal, bool) {
 	switch {
 	case tok.IsIdent():
 		return Match{tok.Text()}, true
+	case tok.IsKeyword():
+		return Match{tok.Text()}, false
 	case tok.IsEOF():
 		return EOF{}, false
 	case tok.Kind() == KindOther:
@@ -64,10 +79,64 @@ func terminalFromToken(tok Token) (Terminal, bool) {
 }
 
 // A context-free rule with an assiciated AST builder.
+// Only one builder is used: Reduce if set, otherwise BuilderErr and then Builder.
+// Errors returned by builders abort the parse. Rules without builders are reduced
+// by the grammar's Reduce builder or produce Nodes if there's none.
+// Tag is arbitrary metadata, such as a label for generic builders.
 type Rule struct {
-	Lhs     string
-	Rhs     []Symbol
-	Builder func([]interface{}) interface{}
+	Lhs        string
+	Rhs        []Symbol
+	Builder    func([]interface{}) interface{}
+	BuilderErr func([]interface{}) (interface{}, error)
+	Reduce     func(*Reduction) (interface{}, error)
+	Tag        interface{}
+	prec       *precedence // binding powers of the rules of operators
+}
+
+// Reduction is an application of a rule passed to Reduce builders.
+type Reduction struct {
+	Rule   *Rule         // the rule being reduced
+	Args   []interface{} // values of the right-hand side symbols
+	Tokens []Token       // tokens covered by the rule, including any trivia between them
+	Span   Span          // source range covered by the rule
+	Env    interface{}   // environment passed to ParseEnv
+	Scope  *Scope        // current scope if the grammar has Scopes
+}
+
+// Positioned is implemented by values that record their source range.
+// Parse sets the span of values built by rules unless they're passed through from the right-hand side.
+type Positioned interface {
+	SetSpan(start, end Position)
+}
+
+func setSpan(v interface{}, args []interface{}, tokens []Token, first, last int) {
+	p, ok := v.(Positioned)
+	if !ok {
+		return
+	}
+	if reflect.TypeOf(v).Comparable() {
+		for _, a := range args {
+			if a == v {
+				return
+			}
+		}
+	}
+	span := spanOf(tokens, first, last)
+	p.SetSpan(span.Start, span.End)
+}
+
+func (gr *Grammar) build(r *Rule, env interface{}, scope *Scope, ar *Arena, args []interface{}, tokens []Token, first, last int) (interface{}, error) {
+	switch {
+	case r.Reduce != nil:
+		return r.Reduce(&Reduction{r, args, tokens[first:last], spanOf(tokens, first, last), env, scope})
+	case r.BuilderErr != nil:
+		return r.BuilderErr(args)
+	case r.Builder != nil:
+		return r.Builder(args), nil
+	case gr.Reduce != nil:
+		return gr.Reduce(&Reduction{r, args, tokens[first:last], spanOf(tokens, first, last), env, scope})
+	}
+	return ar.newNode(r, args, tokens, first, last), nil
 }
 
 func (r *Rule) String() string {
@@ -78,6 +147,16 @@ func (r *Rule) String() string {
 	return ret
 }
 
+// hasSymbol reports whether the right-hand side of a rule has a symbol.
+func (r *Rule) hasSymbol(sym Symbol) bool {
+	for _, s := range r.Rhs {
+		if s == sym {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Rule) stringWithDot(pos int) string {
 	ret := r.Lhs + " ->"
 	for i, s := range r.Rhs {
@@ -108,104 +187,171 @@ func (i1 item) less(i2 item) bool {
 }
 
 type state struct {
-	items []item
+	items   []item
+	id      int
+	succ    map[int32]*state // successors by symbol number during Build
+	rule    int              // number of the rule reduced on any terminal or -1
+	reduces []entry          // reductions on lookahead terminals
+	la      []termSet        // lookaheads of the items in ModeLR1
+	queued  bool             // whether the state is waiting for its successors to be computed
 }
 
-func (s *state) addItem(it item) bool {
-	for _, el := range s.items {
-		if el == it {
-			return false
-		}
+// key returns a string identifying the items of a state.
+func (s *state) key() string {
+	b := make([]byte, 0, 8*len(s.items))
+	for _, it := range s.items {
+		b = strconv.AppendInt(b, int64(it.rule), 10)
+		b = append(b, '.')
+		b = strconv.AppendInt(b, int64(it.dot), 10)
+		b = append(b, ' ')
 	}
-	s.items = append(s.items, it)
-	sort.Slice(s.items, func(i, j int) bool {
-		i1, i2 := s.items[i], s.items[j]
-		return i1.less(i2)
-	})
-	return true
+	return string(b)
 }
 
-func (s1 *state) Compare(s2 interface{}) int {
-	if s2, ok := s2.(*state); ok {
+func (s1 *state) compare(s2 *state) int {
+	switch {
+	case len(s1.items) < len(s2.items):
+		return -1
+	case len(s1.items) > len(s2.items):
+		return 1
+	}
+	for i, i1 := range s1.items {
+		i2 := s2.items[i]
 		switch {
-		case len(s1.items) < len(s2.items):
+		case i1.less(i2):
 			return -1
-		case len(s1.items) > len(s2.items):
+		case i2.less(i1):
 			return 1
 		}
-		for i, i1 := range s1.items {
-			i2 := s2.items[i]
+	}
+	for i, la1 := range s1.la {
+		la2 := s2.la[i]
+		for j, w := range la1 {
 			switch {
-			case i1.less(i2):
+			case w < la2[j]:
 				return -1
-			case i2.less(i1):
+			case w > la2[j]:
 				return 1
 			}
 		}
-		return 0
 	}
-	panic("Compare argument is not a state pointer")
+	return 0
 }
 
-type action interface{}
+// byItem sorts the items of a state keeping their lookaheads with them.
+type byItem state
+
+func (s *byItem) Len() int { return len(s.items) }
 
-type stop struct{}
+func (s *byItem) Less(i, j int) bool { return s.items[i].less(s.items[j]) }
+
+func (s *byItem) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	if s.la != nil {
+		s.la[i], s.la[j] = s.la[j], s.la[i]
+	}
+}
+
+type action interface{}
 
 type shift struct{ state *state }
 
 type reduce struct{ rule *Rule }
 
-// An attribute LR-grammar.
+// An attribute LR-grammar. A grammar is where rules are assembled: its fields may be changed
+// until it's built, and Compile returns an immutable Language to parse with. If rules are added,
+// removed or replaced after Build, parses fail with ErrModified until the grammar is built again.
+// Reduce is the builder for rules that don't have their own.
+// Hooks, if set, receive the durations of building, parsing and of the builders.
+// Limits cap the resources used by every parse. Mode selects the construction of the automaton.
+// Workers is the number of goroutines Build uses to compute states; a negative number uses
+// GOMAXPROCS and 0 or 1 builds on the calling goroutine. The automaton doesn't depend on it.
+// Lazy makes the first parse build the grammar unless it's been built, so that grammars that
+// are never used cost nothing; the parses then fail with the error of Build if there was one.
+// Tokeniser is used by ParseString and ParseReader; if it's nil, they use the default one.
+// Preprocess, if set, transforms the tokens of ParseString and ParseReader before they're parsed.
+// Scopes, if set, make parses maintain a symbol table exposed to Reduce builders.
+// Engine, if set, replaces the LR automaton and driver. Placeholders maps the names of
+// placeholder tokens to the non-terminals they stand for; others stand for the non-terminal
+// of their name.
+//
+// A built grammar is safe for concurrent use: parses keep no state in the grammar and use
+// the automaton and reduce hooks that were current when they started, so Build, LoadTables
+// and OnReduce may be called while other goroutines parse. The exported fields and the rules
+// themselves must not be modified during parses.
 type Grammar struct {
 	Rules        []*Rule
-	actions      *rbtree.Tree
-	gotos        *rbtree.Tree
-	nonterminals map[NonTerminal]struct{}
-	terminals    map[Terminal]struct{}
-	initState    *state
+	Reduce       func(*Reduction) (interface{}, error)
+	Hooks        Hooks
+	Limits       Limits
+	Mode         Mode
+	Workers      int
+	Engine       Engine
+	Placeholders map[string]string
+	Lazy         bool
+	Tokeniser    *Tokeniser
+	Preprocess   Preprocessor
+	Scopes       *Scopes
+	once         sync.Once    // guards the lazy build
+	lazyErr      error        // error of the lazy build
+	mu           sync.RWMutex // guards the automaton and hooks
+	onReduce     []reduceHook
+	*automaton
 }
 
 // NewGrammar creates a new grammar with the given rules.
 func NewGrammar(rules []*Rule) *Grammar {
-	return &Grammar{
-		Rules:        rules,
-		actions:      rbtree.New(),
-		gotos:        rbtree.New(),
-		nonterminals: make(map[NonTerminal]struct{}),
-		terminals:    make(map[Terminal]struct{})}
-}
-
-// func (gr *Grammar) Automaton() {
-// 	keys := gr.actions.Keys()
-// 	states := make(map[int]*state)
-// 	nums := rbtree.New()
-// 	var initState int
-// 	for i, k := range keys {
-// 		s := k.(*state)
-// 		states[i] = s
-// 		nums.Insert(s, i)
-// 		if s == gr.initState {
-// 			initState = i
-// 		}
-// 	}
-// }
-
-func (gr *Grammar) itemAsString(it item) string {
-	r := gr.Rules[it.rule]
+	return &Grammar{Rules: rules}
+}
+
+// MustGrammar creates a grammar with the given rules and builds it. It panics if Build fails,
+// which simplifies the initialisation of package-level grammars.
+func MustGrammar(rules ...*Rule) *Grammar {
+	gr := NewGrammar(rules)
+	if err := gr.Build(); err != nil {
+		panic("shred: MustGrammar: " + err.Error())
+	}
+	return gr
+}
+
+// OnReduce registers a hook called after every reduction with the rule, the values of its right-hand side
+// and the built value. The slice of values must not be retained. Hooks are called in registration order.
+func (gr *Grammar) OnReduce(fn func(rule *Rule, children []interface{}, result interface{})) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.onReduce = append(gr.onReduce[:len(gr.onReduce):len(gr.onReduce)], fn)
+}
+
+// current returns the current automaton and reduce hooks.
+func (gr *Grammar) current() (*automaton, []reduceHook) {
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+	return gr.automaton, gr.onReduce
+}
+
+// setAutomaton replaces the automaton used by new parses.
+func (gr *Grammar) setAutomaton(a *automaton) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.automaton = a
+}
+
+func (a *automaton) itemAsString(it item) string {
+	r := a.rules[it.rule]
 	return r.stringWithDot(it.dot)
 }
 
-func (gr *Grammar) stateAsString(s *state) string {
+func (a *automaton) stateAsString(s *state) string {
 	ret := make([]string, len(s.items))
 	for i, it := range s.items {
-		ret[i] = gr.itemAsString(it)
+		ret[i] = a.itemAsString(it)
 	}
 	return strings.Join(ret, " + ")
 }
 
-func (gr *Grammar) rulesWithLhs(lhs string) []int {
+func (a *automaton) rulesWithLhs(lhs string) []int {
 	var ret []int
-	for i, r := range gr.Rules {
+	for i, r := range a.rules {
 		if r.Lhs == lhs {
 			ret = append(ret, i)
 		}
@@ -213,207 +359,554 @@ func (gr *Grammar) rulesWithLhs(lhs string) []int {
 	return ret
 }
 
-func (gr *Grammar) stateNonTerminals(s *state) map[NonTerminal]*state {
-	m := make(map[NonTerminal]*state)
-	for _, it := range s.items {
-		r := gr.Rules[it.rule]
-		if it.dot < len(r.Rhs) {
-			if nt, ok := r.Rhs[it.dot].(NonTerminal); ok {
-				s := m[nt]
-				if s == nil {
-					s = new(state)
-					m[nt] = s
-				}
-				s.addItem(item{it.rule, it.dot + 1})
+// successors returns the closed kernels of the successors of a state by symbol number.
+func (a *automaton) successors(s *state) map[int32]*state {
+	m := make(map[int32]*state)
+	for i, it := range s.items {
+		rhs := a.rhs[it.rule]
+		if it.dot < len(rhs) {
+			s2 := m[rhs[it.dot]]
+			if s2 == nil {
+				s2 = &state{rule: -1}
+				m[rhs[it.dot]] = s2
+			}
+			s2.items = append(s2.items, item{it.rule, it.dot + 1})
+			if s.la != nil {
+				s2.la = append(s2.la, s.la[i].clone())
 			}
 		}
 	}
 	for _, s := range m {
-		gr.closeState(s)
+		a.closeState(s)
 	}
 	return m
 }
 
-func (gr *Grammar) stateTerminals(s *state) map[Terminal]*state {
-	m := make(map[Terminal]*state)
+func (a *automaton) reductions(s *state) []*Rule {
+	var ret []*Rule
 	for _, it := range s.items {
-		r := gr.Rules[it.rule]
-		if it.dot < len(r.Rhs) {
-			if t, ok := r.Rhs[it.dot].(Terminal); ok {
-				s := m[t]
-				if s == nil {
-					s = new(state)
-					m[t] = s
+		r := a.rules[it.rule]
+		if it.dot == len(r.Rhs) {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// closeState adds the items predicted by the items of a state and sorts them.
+// Every item is processed once using a worklist. In ModeLR1, the lookaheads
+// of the predicted items are then propagated until they don't change.
+func (a *automaton) closeState(s *state) {
+	if a.reuse != nil {
+		if items, ok := a.reuse[s.key()]; ok {
+			s.items = items
+			return
+		}
+	}
+	seen := make(map[item]int, len(s.items))
+	for i, it := range s.items {
+		seen[it] = i
+	}
+	for i := 0; i < len(s.items); i++ {
+		it := s.items[i]
+		rhs := a.rhs[it.rule]
+		if it.dot < len(rhs) && rhs[it.dot] < 0 {
+			for _, r := range a.ntRules[-rhs[it.dot]-1] {
+				it := item{r, 0}
+				if _, ok := seen[it]; !ok {
+					seen[it] = len(s.items)
+					s.items = append(s.items, it)
 				}
-				s.addItem(item{it.rule, it.dot + 1})
 			}
 		}
 	}
-	for _, s := range m {
-		gr.closeState(s)
+	if s.la != nil {
+		for len(s.la) < len(s.items) {
+			s.la = append(s.la, newTermSet(len(a.terms)))
+		}
+		for changed := true; changed; {
+			changed = false
+			for i, it := range s.items {
+				rhs := a.rhs[it.rule]
+				if it.dot == len(rhs) || rhs[it.dot] >= 0 {
+					continue
+				}
+				first, nullable := a.firstOf(it.rule, it.dot+1)
+				for _, r := range a.ntRules[-rhs[it.dot]-1] {
+					la := s.la[seen[item{r, 0}]]
+					if la.union(first) {
+						changed = true
+					}
+					if nullable && la.union(s.la[i]) {
+						changed = true
+					}
+				}
+			}
+		}
 	}
-	return m
+	sort.Sort((*byItem)(s))
 }
 
-func (gr *Grammar) reductions(s *state) []*Rule {
-	var ret []*Rule
-	for _, it := range s.items {
-		r := gr.Rules[it.rule]
-		if it.dot == len(r.Rhs) {
-			ret = append(ret, r)
+// addState adds a state to the automaton unless it's already there and returns the canonical instance of the state.
+// New states are queued to have their successors computed by expandStates. In ModeLR1, the state is merged
+// with a compatible one with the same items, which is queued again if its lookaheads grow.
+func (a *automaton) addState(s *state) *state {
+	k := s.key()
+	for _, s2 := range a.canon[k] {
+		if s.la == nil {
+			return s2
+		}
+		if compatible(s, s2) {
+			grown := false
+			for i, la := range s2.la {
+				if la.union(s.la[i]) {
+					grown = true
+				}
+			}
+			if grown && !s2.queued {
+				s2.queued = true
+				a.queue = append(a.queue, s2)
+			}
+			return s2
 		}
 	}
-	return ret
+	a.canon[k] = append(a.canon[k], s)
+	s.queued = true
+	a.queue = append(a.queue, s)
+	return s
 }
 
-func (gr *Grammar) closeState(s *state) {
-	processed := make(map[item]struct{})
-	for {
-		var newItems []item
-		for _, it := range s.items {
-			if _, ok := processed[it]; ok {
+// compatible reports whether two states with the same items can be merged without causing
+// reduce/reduce conflicts that neither of them has. Only the kernel items are checked since
+// the lookaheads of the others are derived from them.
+func compatible(s1, s2 *state) bool {
+	for i, it := range s1.items {
+		if it.dot == 0 {
+			continue
+		}
+		for j := i + 1; j < len(s1.items); j++ {
+			if s1.items[j].dot == 0 {
 				continue
 			}
-			processed[it] = struct{}{}
-			r := gr.Rules[it.rule]
-			if it.dot < len(r.Rhs) {
-				if nt, ok := r.Rhs[it.dot].(NonTerminal); ok {
-					for _, r := range gr.rulesWithLhs(nt.Name) {
-						newItems = append(newItems, item{r, 0})
-					}
+			if s1.la[i].intersects(s2.la[j]) || s2.la[i].intersects(s1.la[j]) {
+				if !s1.la[i].intersects(s1.la[j]) && !s2.la[i].intersects(s2.la[j]) {
+					return false
 				}
 			}
 		}
-		added := false
-		for _, it := range newItems {
-			if s.addItem(it) {
-				added = true
+	}
+	return true
+}
+
+// expandStates computes the reductions and successors of the queued states adding the successors
+// until there are no new states. It uses an explicit worklist so that the size of the automaton
+// isn't limited by the depth of the goroutine stack. The queue is processed in rounds: the successors
+// of a round's states are computed by the workers, then they're added in queue order so that
+// the result is the same for any number of workers.
+func (a *automaton) expandStates(workers int) {
+	for len(a.queue) > 0 {
+		round := a.queue
+		a.queue = nil
+		for _, s := range round {
+			s.queued = false
+		}
+		a.computeSuccessors(round, workers)
+		for _, s := range round {
+			// fmt.Println("new state:", a.stateAsString(s))
+			switch a.mode {
+			case ModeLR0:
+				a.reduceAny(s)
+			case ModeSLR:
+				a.reduceLookahead(s)
+			}
+			// successors are added in the order of their symbols so that the states are queued,
+			// merged and numbered in the same order in every build
+			for _, sym := range sortedSymbols(s.succ) {
+				// fmt.Println("successor:", sym, "=>", a.stateAsString(s.succ[sym]))
+				s.succ[sym] = a.addState(s.succ[sym])
 			}
 		}
-		if !added {
-			break
+	}
+}
+
+// sortedSymbols returns the symbol numbers of the successors of a state in increasing order.
+func sortedSymbols(succ map[int32]*state) []int32 {
+	syms := make([]int32, 0, len(succ))
+	for sym := range succ {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool { return syms[i] < syms[j] })
+	return syms
+}
+
+// computeSuccessors computes the successors of states using a pool of workers.
+// Small rounds are computed on the calling goroutine.
+func (a *automaton) computeSuccessors(states []*state, workers int) {
+	if workers > len(states) {
+		workers = len(states)
+	}
+	if workers <= 1 {
+		for _, s := range states {
+			s.succ = a.successors(s)
 		}
+		return
+	}
+	var wg sync.WaitGroup
+	next := int64(-1)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := atomic.AddInt64(&next, 1); i < int64(len(states)); i = atomic.AddInt64(&next, 1) {
+				states[i].succ = a.successors(states[i])
+			}
+		}()
 	}
+	wg.Wait()
 }
 
-func (gr *Grammar) addState(s *state) error {
-	if _, ok := gr.actions.Get(s); ok {
-		return nil
+// completed returns the numbers of the rules completed in a state.
+func (a *automaton) completed(s *state) []int {
+	var ret []int
+	for _, it := range s.items {
+		if it.dot == len(a.rhs[it.rule]) {
+			ret = append(ret, it.rule)
+		}
+	}
+	return ret
+}
+
+// reduceAny sets the reduction of a state on any terminal. Shifts take precedence.
+func (a *automaton) reduceAny(s *state) {
+	rs := a.completed(s)
+	if len(rs) == 0 {
+		return
 	}
-	// fmt.Println("new state:", gr.stateAsString(s))
-	a := make(map[Terminal]action)
-	gr.actions.Insert(s, a)
-	rs := gr.reductions(s)
 	if len(rs) > 1 {
-		return errors.New("too many reductions for state " + gr.stateAsString(s))
+		a.conflicts = append(a.conflicts, Conflict{a.stateAsString(s), a.reductions(s)})
 	}
-	if len(rs) == 1 {
-		r := rs[0]
-		// fmt.Println("reduction:", r)
-		for t := range gr.terminals {
-			a[t] = reduce{r}
+	s.rule = rs[0]
+	for sym := range s.succ {
+		if sym >= 0 {
+			a.shiftReduce++
 		}
 	}
-	for t, s2 := range gr.stateTerminals(s) {
-		// fmt.Println("shift:", t, "=>", gr.stateAsString(s2))
-		if act, ok := a[t]; ok {
-			if _, ok := act.(shift); ok {
-				return errors.New("multiples shifts over '" + t.String() + "' for " + gr.stateAsString(s))
+}
+
+// reduceLookahead sets the reductions of a state on their lookahead terminals. Shifts take precedence.
+func (a *automaton) reduceLookahead(s *state) {
+	rs := a.completed(s)
+	if len(rs) == 0 {
+		return
+	}
+	byTerm := make([][]int, len(a.terms))
+	for _, r := range rs {
+		a.lookaheads(s, r).each(func(t int32) { byTerm[t] = append(byTerm[t], r) })
+	}
+	conflicting := make(map[int]bool)
+	for t, rs := range byTerm {
+		switch {
+		case len(rs) == 0:
+		case s.succ[int32(t)] != nil:
+			a.shiftReduce++
+		default:
+			if len(rs) > 1 {
+				for _, r := range rs {
+					conflicting[r] = true
+				}
 			}
+			s.reduces = append(s.reduces, entry{int32(t), -int32(rs[0]) - 1})
 		}
-		a[t] = shift{s2}
-		gr.addState(s2)
 	}
-	g := make(map[NonTerminal]*state)
-	gr.gotos.Insert(s, g)
-	for nt, s2 := range gr.stateNonTerminals(s) {
-		// fmt.Println("goto:", nt, "=>", gr.stateAsString(s2))
-		g[nt] = s2
-		gr.addState(s2)
+	if len(conflicting) > 0 {
+		var crs []*Rule
+		for _, r := range rs {
+			if conflicting[r] {
+				crs = append(crs, a.rules[r])
+			}
+		}
+		a.conflicts = append(a.conflicts, Conflict{a.stateAsString(s), crs})
 	}
-	return nil
 }
 
-// Build builds an automaton for the grammar.
-func (gr *Grammar) Build() error {
-	for _, r := range gr.Rules {
-		for _, s := range r.Rhs {
-			switch s := s.(type) {
-			case NonTerminal:
-				gr.nonterminals[s] = struct{}{}
-			case Terminal:
-				gr.terminals[s] = struct{}{}
+// lookaheads returns the terminals on which a rule completed in a state is reduced.
+func (a *automaton) lookaheads(s *state, rule int) termSet {
+	if s.la != nil {
+		for i, it := range s.items {
+			if it.rule == rule && it.dot == len(a.rhs[rule]) {
+				return s.la[i]
 			}
 		}
 	}
-	gr.terminals[EOF{}] = struct{}{}
-	s := new(state)
-	for _, r := range gr.rulesWithLhs("0") {
-		s.items = append(s.items, item{r, 0})
+	return a.follow[a.ruleLhs[rule]]
+}
+
+// Build builds an automaton for the grammar.
+// If there are conflicts, all of them are reported in a ConflictError.
+// The construction is deterministic: the same rules give the same states, tables and conflicts
+// in the same order, so that reports and generated parsers can be diffed.
+func (gr *Grammar) Build() error {
+	if gr.Hooks != nil {
+		defer phaseDone(gr.Hooks, PhaseBuild, time.Now())
 	}
-	gr.closeState(s)
-	gr.initState = s
-	err := gr.addState(s)
-	if err != nil {
-		return err
+	if gr.Engine != nil {
+		return gr.Engine.Build(gr.Rules)
+	}
+	return gr.buildFrom(nil)
+}
+
+// buildFrom builds an automaton for the grammar reusing the closures of the states of a previous one.
+func (gr *Grammar) buildFrom(prev *automaton) error {
+	a := newAutomaton(gr.Rules, gr.Mode, gr.Workers, prev)
+	gr.setAutomaton(a)
+	if len(a.conflicts) > 0 {
+		return &ConflictError{a.conflicts}
 	}
 	return nil
 }
 
+// buildLazily builds a lazy grammar on its first parse unless it's been built
+// and returns the error of the build.
+func (gr *Grammar) buildLazily() error {
+	if !gr.Lazy {
+		return nil
+	}
+	gr.once.Do(func() {
+		if a, _ := gr.current(); a == nil || gr.Engine != nil {
+			gr.lazyErr = gr.Build()
+		}
+	})
+	return gr.lazyErr
+}
+
+// built returns the current automaton or ErrNotBuilt if the grammar hasn't been built.
+func (gr *Grammar) built() (*automaton, error) {
+	a, _ := gr.current()
+	if a == nil {
+		return nil, ErrNotBuilt
+	}
+	return a, nil
+}
+
+// stale reports whether rules were added, removed or replaced since the automaton was built.
+// Parses fail with ErrModified if they were.
+func (a *automaton) stale(rules []*Rule) bool {
+	if len(rules) != len(a.rules) {
+		return true
+	}
+	for i, r := range rules {
+		if r != a.rules[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Conflicts returns the conflicts found by Build or nil if the grammar isn't built.
+func (gr *Grammar) Conflicts() []Conflict {
+	a, _ := gr.current()
+	if a == nil {
+		return nil
+	}
+	return a.conflicts
+}
+
 // Parse parses a sequence of tokens.
 func (gr *Grammar) Parse(tokens []Token) (interface{}, error) {
-	var stack []interface{}
-	st, i := gr.initState, 0
-	states := []*state{st}
-	for {
-		tok := tokens[i]
-		a, ok := gr.actions.Get(st)
-		if !ok {
-			return nil, errors.New("no actions for state " + gr.stateAsString(st))
-		}
-		as := a.(map[Terminal]action)
-		t, id := terminalFromToken(tok)
-		act, ok := as[t]
-		if !ok && id {
-			act, ok = as[Ident{}]
+	return gr.ParseEnv(nil, tokens)
+}
+
+// MustParse parses a sequence of tokens and panics if the parse fails.
+func (gr *Grammar) MustParse(tokens []Token) interface{} {
+	v, err := gr.Parse(tokens)
+	if err != nil {
+		panic("shred: MustParse: " + err.Error())
+	}
+	return v
+}
+
+// ParseEnv parses a sequence of tokens passing an environment to Reduce builders.
+// The environment can hold per-parse state such as symbol tables.
+func (gr *Grammar) ParseEnv(env interface{}, tokens []Token) (interface{}, error) {
+	return gr.parseValues(tokens, gr.values(env), nil)
+}
+
+// ParseContext parses a sequence of tokens until the context is done, in which case
+// it fails with the context's error. The context is checked periodically.
+func (gr *Grammar) ParseContext(ctx context.Context, tokens []Token) (interface{}, error) {
+	vb := gr.values(nil)
+	v, _, err := gr.run(ctx, tokens, nil, &vb, nil)
+	return v, err
+}
+
+// ParsePrefix parses a sequence of tokens and returns the index of the first token that isn't
+// part of the parse, or of the offending token if the parse fails. If that token isn't EOF,
+// the input has trailing tokens, which Parse ignores. Grammars built in ModeLR0 reduce the start
+// symbol regardless of the next token, so they can parse a sequence of concatenated documents
+// one by one; the other modes expect EOF after the start symbol. With an engine, the index is
+// that of the EOF token.
+func (gr *Grammar) ParsePrefix(tokens []Token) (interface{}, int, error) {
+	vb := gr.values(nil)
+	return gr.run(nil, tokens, nil, &vb, nil)
+}
+
+// ParseItem parses one item, the first complete derivation of the start symbol at the beginning
+// of a sequence of tokens, and returns it with the remaining tokens so that it can be called again
+// on them, as an interpreter reading one statement at a time does. The parser takes the longest
+// item: it stops before the first token that can't continue the item and reduces the start symbol
+// as if it were EOF. Trivia before the remaining tokens are skipped, so the input is exhausted
+// when the first remaining token is EOF. If the parse fails, the remaining tokens start
+// with the offending one; if they're incomplete as reported by Incomplete, an interactive shell
+// should append the next line to them and parse again.
+func (gr *Grammar) ParseItem(tokens []Token) (interface{}, []Token, error) {
+	p := gr.NewParser(tokens)
+	p.item = true
+	v, err := p.Run()
+	i := p.i
+	for i < len(tokens)-1 && IsTrivia(tokens[i]) {
+		i++
+	}
+	return v, tokens[i:], err
+}
+
+// ParseString tokenises a string with the grammar's tokeniser and parses it.
+func (gr *Grammar) ParseString(s string) (interface{}, error) {
+	return gr.ParseReader(strings.NewReader(s))
+}
+
+// ParseReader tokenises the contents of a reader with the grammar's tokeniser, preprocesses
+// the tokens if the grammar has a preprocessor and parses them.
+func (gr *Grammar) ParseReader(r io.Reader) (interface{}, error) {
+	tz := gr.Tokeniser
+	if tz == nil {
+		tz = new(Tokeniser)
+	}
+	tokens, err := gr.preprocess(tz.Tokenise(r))
+	if err != nil {
+		return nil, err
+	}
+	return gr.Parse(tokens)
+}
+
+// builder constructs the values of symbols during a parse.
+type builder interface {
+	shift(tokens []Token, i int) (interface{}, error)
+	reduce(r *Rule, args []interface{}, tokens []Token, first, last int) (interface{}, error)
+}
+
+// valueBuilder calls the rules' builders.
+type valueBuilder struct {
+	gr    *Grammar
+	env   interface{}
+	hooks []reduceHook
+	arena *Arena
+	table *symbolTable
+	smap  *SourceMap // source map being built or nil
+	memo  *Memo
+}
+
+// values returns a builder calling the rules' builders and the current reduce hooks.
+func (gr *Grammar) values(env interface{}) valueBuilder {
+	_, hooks := gr.current()
+	vb := valueBuilder{gr: gr, env: env, hooks: hooks}
+	if gr.Scopes != nil {
+		vb.table = gr.Scopes.newTable(gr.Rules)
+	}
+	return vb
+}
+
+func (b valueBuilder) shift(tokens []Token, i int) (interface{}, error) {
+	if b.smap != nil {
+		b.smap.add(tokens[i], i, i+1)
+	}
+	return tokens[i], nil
+}
+
+func (b valueBuilder) reduce(r *Rule, args []interface{}, tokens []Token, first, last int) (interface{}, error) {
+	var start time.Time
+	if b.gr.Hooks != nil {
+		start = time.Now()
+	}
+	var act *scopeAction
+	var scope *Scope
+	if b.table != nil {
+		if act = b.table.actions[r]; act != nil {
+			if err := b.table.resolve(act, args); err != nil {
+				return nil, err
+			}
 		}
-		if !ok {
-			return nil, errors.New("no action over '" + t.String() + "' for state " + gr.stateAsString(st))
+		scope = b.table.current
+	}
+	var key string
+	if b.memo != nil && act == nil {
+		var v interface{}
+		var ok bool
+		if key, v, ok = b.memo.lookup(r, args); ok {
+			b.built(r, args, v, first, last)
+			return v, nil
 		}
-		switch act := act.(type) {
-		case stop:
-			return stack[len(stack)-1], nil
-		case shift:
-			stack = append(stack, tok)
-			st = act.state
-			states = append(states, st)
-			i++
-		case reduce:
-			r := act.rule
-			l := len(r.Rhs)
-			data := stack[len(stack)-l:]
-			stack = append(stack[:len(stack)-l], r.Builder(data))
-			if r.Lhs == "0" {
-				if len(stack) != 1 {
-					panic("corrupted symbol stack")
-				}
-				return stack[len(stack)-1], nil
-			}
-			states = states[:len(states)-l]
-			pst := states[len(states)-1]
-			g, ok := gr.gotos.Get(pst)
-			if !ok {
-				return nil, errors.New("no gotos for state " + gr.stateAsString(st))
-			}
-			gt := g.(map[NonTerminal]*state)
-			st2, ok := gt[NonTerminal{r.Lhs}]
-			if !ok {
-				return nil, errors.New("no goto over '" + r.Lhs + "' for state " + gr.stateAsString(st))
-			}
-			st = st2
-			states = append(states, st)
-		default:
-			panic("unknown action")
+	}
+	v, err := b.gr.build(r, b.env, scope, b.arena, args, tokens, first, last)
+	if b.gr.Hooks != nil {
+		b.gr.Hooks.Reduce(r, time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if act != nil {
+		if err := b.table.reduced(act, args, v); err != nil {
+			return nil, err
 		}
 	}
+	setSpan(v, args, tokens, first, last)
+	if key != "" {
+		b.memo.store(key, v)
+	}
+	b.built(r, args, v, first, last)
+	return v, nil
+}
+
+// built records a value built by a reduction in the source map and reports it to the reduce hooks.
+func (b valueBuilder) built(r *Rule, args []interface{}, v interface{}, first, last int) {
+	if b.smap != nil {
+		b.smap.add(v, first, last)
+	}
+	reduced(b.hooks, r, args, v)
+}
+
+// nodeBuilder builds Nodes ignoring the rules' builders.
+type nodeBuilder struct {
+	hooks []reduceHook
+}
+
+func (b nodeBuilder) shift(tokens []Token, i int) (interface{}, error) { return tokens[i], nil }
+
+func (b nodeBuilder) reduce(r *Rule, args []interface{}, tokens []Token, first, last int) (interface{}, error) {
+	v := newNode(r, args, tokens, first, last)
+	reduced(b.hooks, r, args, v)
+	return v, nil
+}
+
+// reduceHook is a hook registered by OnReduce.
+type reduceHook func(rule *Rule, children []interface{}, result interface{})
+
+// reduced calls the reduction hooks.
+func reduced(hooks []reduceHook, r *Rule, args []interface{}, v interface{}) {
+	for _, fn := range hooks {
+		fn(r, args, v)
+	}
+}
+
+// positioned wraps an error in a parse error at a token unless it's already positioned.
+func positioned(err error, tok Token) error {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return err
+	}
+	return &ParseError{Pos: tok.Pos(), Err: err, Token: tok}
+}
+
+// ParseTrace parses a sequence of tokens reporting every step to a tracer.
+func (gr *Grammar) ParseTrace(tokens []Token, tr Tracer) (interface{}, error) {
+	return gr.parseValues(tokens, gr.values(nil), tr)
 }
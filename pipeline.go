@@ -0,0 +1,62 @@
+package shred
+
+import "io"
+
+const (
+	pipelineBatch = 512 // number of tokens sent to the parser at once
+	pipelineDepth = 8   // number of batches buffered between the tokeniser and the parser
+)
+
+// ParseConcurrent tokenises the contents of a reader with the grammar's tokeniser in another
+// goroutine while it parses them, so that reading, tokenising and parsing large inputs overlap.
+// The tokens are passed to the parser in batches through a bounded buffer; the tokeniser stops
+// when the parse ends. The result is that of ParseReader, which is used instead for grammars
+// with a preprocessor or an engine since they need all tokens at once.
+func (gr *Grammar) ParseConcurrent(r io.Reader) (interface{}, error) {
+	if gr.Preprocess != nil || gr.Engine != nil {
+		return gr.ParseReader(r)
+	}
+	tz := gr.Tokeniser
+	if tz == nil {
+		tz = new(Tokeniser)
+	}
+	batches := make(chan []Token, pipelineDepth)
+	done := make(chan struct{})
+	defer close(done)
+	go tz.scanBatches(r, batches, done)
+	p := gr.NewParser(nil)
+	p.src = batches
+	return p.Run()
+}
+
+// scanBatches tokenises the contents of a reader sending the tokens in batches until EOF
+// or until done is closed.
+func (tz *Tokeniser) scanBatches(r io.Reader, out chan<- []Token, done <-chan struct{}) {
+	defer close(out)
+	s := tz.NewScanner(r)
+	for eof := false; !eof; {
+		batch := make([]Token, 0, pipelineBatch)
+		for len(batch) < pipelineBatch && !eof {
+			tok := s.Next()
+			batch = append(batch, tok)
+			eof = tok.IsEOF()
+		}
+		select {
+		case out <- batch:
+		case <-done:
+			return
+		}
+	}
+}
+
+// fill receives batches of tokens until the next token has arrived.
+func (p *Parser) fill() {
+	for p.i >= len(p.tokens) && p.src != nil {
+		batch, ok := <-p.src
+		if !ok {
+			p.src = nil
+			break
+		}
+		p.tokens = append(p.tokens, batch...)
+	}
+}
@@ -0,0 +1,51 @@
+package shred
+
+import "sort"
+
+// Placeholder is a hole in a pattern: a placeholder token parsed in place of a non-terminal,
+// so that templates and macro patterns with metavariables can be parsed with the grammar
+// of the language. Placeholders are tokenised by a Tokeniser with Placeholders set and are
+// passed to builders as values of the non-terminals they stand for.
+type Placeholder struct {
+	Name        string // name of the placeholder without the $
+	NonTerminal string // non-terminal the placeholder stands for
+	Token       Token
+}
+
+// String returns the placeholder as written in the source.
+func (p *Placeholder) String() string { return "$" + p.Name }
+
+// newPlaceholder returns the placeholder of a token. The non-terminal is given by the
+// grammar's Placeholders or is the name itself.
+func newPlaceholder(tok Token, holes map[string]string) *Placeholder {
+	name := tok.Text()
+	nt, ok := holes[name]
+	if !ok {
+		nt = name
+	}
+	return &Placeholder{name, nt, tok}
+}
+
+// placeholderAction returns the encoded action of a state for a placeholder standing for
+// a non-terminal. If the state has a goto over the non-terminal, the placeholder is shifted
+// to its target, otherwise the state's reduction is performed if it has only one.
+func (a *automaton) placeholderAction(st int, nt string) int32 {
+	i := sort.SearchStrings(a.nts, nt)
+	if i == len(a.nts) || a.nts[i] != nt {
+		return 0
+	}
+	if g := a.gotoAt(st, i); g > 0 {
+		return g
+	}
+	var act int32
+	for t := range a.terms {
+		switch r := a.actionAt(st, t); {
+		case r >= 0:
+		case act == 0:
+			act = r
+		case act != r:
+			return 0
+		}
+	}
+	return act
+}
@@ -0,0 +1,43 @@
+package shred
+
+// Preprocessor transforms a sequence of tokens ending with EOF before it's parsed, e.g. by expanding
+// macros or includes. It returns a sequence ending with EOF. The tokens it inserts in place
+// of others should be created with Expanded so that their provenance is known.
+// Errors returned by preprocessors are passed through.
+type Preprocessor func(tokens []Token) ([]Token, error)
+
+// preprocess runs the grammar's preprocessor if it has one.
+func (gr *Grammar) preprocess(tokens []Token) ([]Token, error) {
+	if gr.Preprocess == nil {
+		return tokens, nil
+	}
+	tokens, err := gr.Preprocess(tokens)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 || !tokens[len(tokens)-1].IsEOF() {
+		panic("shred: preprocessor returned tokens without EOF")
+	}
+	return tokens, nil
+}
+
+// expandedToken is a token inserted by a preprocessor in place of another.
+type expandedToken struct {
+	Token
+	from Token
+}
+
+// Expanded returns a token that is tok produced in place of the token from, such as a token of
+// the body of a macro inserted in place of the macro's name. The returned token has the text,
+// kind and position of tok, so syntax errors are reported in the macro's body, and ExpandedFrom
+// returns from.
+func Expanded(tok, from Token) Token { return &expandedToken{tok, from} }
+
+// ExpandedFrom returns the token in place of which a token was inserted by Expanded or nil.
+// Applied repeatedly, it returns the chain of expansions that produced the token.
+func ExpandedFrom(tok Token) Token {
+	if e, ok := tok.(*expandedToken); ok {
+		return e.from
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package shred
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Phase is a stage of processing input.
+type Phase byte
+
+const (
+	PhaseTokenise Phase = iota
+	PhaseBuild
+	PhaseParse
+)
+
+// String returns the name of the phase.
+func (p Phase) String() string {
+	switch p {
+	case PhaseTokenise:
+		return "tokenise"
+	case PhaseBuild:
+		return "build"
+	case PhaseParse:
+		return "parse"
+	}
+	return fmt.Sprintf("phase(%d)", p)
+}
+
+// Hooks receives timings. It must be safe for concurrent use if the tokeniser or grammar is.
+type Hooks interface {
+	// Phase is called when a phase has finished.
+	Phase(p Phase, d time.Duration)
+	// Reduce is called after the builder of a rule has been run.
+	Reduce(rule *Rule, d time.Duration)
+}
+
+func phaseDone(h Hooks, p Phase, start time.Time) { h.Phase(p, time.Since(start)) }
+
+// Profile is a Hooks implementation that accumulates timings. It's safe for concurrent use.
+type Profile struct {
+	mu     sync.Mutex
+	phases [PhaseParse + 1]struct {
+		n int
+		d time.Duration
+	}
+	rules map[*Rule]*RuleProfile
+}
+
+// RuleProfile is the accumulated reduction count and builder time of a rule.
+type RuleProfile struct {
+	Rule     *Rule
+	Count    int
+	Duration time.Duration
+}
+
+// Phase accumulates the duration of a phase.
+func (p *Profile) Phase(ph Phase, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if int(ph) < len(p.phases) {
+		p.phases[ph].n++
+		p.phases[ph].d += d
+	}
+}
+
+// Reduce accumulates the duration of a builder.
+func (p *Profile) Reduce(rule *Rule, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rules == nil {
+		p.rules = make(map[*Rule]*RuleProfile)
+	}
+	rp := p.rules[rule]
+	if rp == nil {
+		rp = &RuleProfile{Rule: rule}
+		p.rules[rule] = rp
+	}
+	rp.Count++
+	rp.Duration += d
+}
+
+// PhaseTotal returns how many times a phase ran and its total duration.
+func (p *Profile) PhaseTotal(ph Phase) (int, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.phases[ph].n, p.phases[ph].d
+}
+
+// Rules returns the profiles of the reduced rules ordered by decreasing total duration.
+func (p *Profile) Rules() []RuleProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ret := make([]RuleProfile, 0, len(p.rules))
+	for _, rp := range p.rules {
+		ret = append(ret, *rp)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Duration > ret[j].Duration })
+	return ret
+}
+
+// WriteReport writes the phase totals and the rule profiles.
+func (p *Profile) WriteReport(w io.Writer) error {
+	var sb strings.Builder
+	for ph := PhaseTokenise; ph <= PhaseParse; ph++ {
+		n, d := p.PhaseTotal(ph)
+		fmt.Fprintf(&sb, "%-8s %8d runs %14s\n", ph, n, d)
+	}
+	for _, rp := range p.Rules() {
+		fmt.Fprintf(&sb, "%8d reductions %14s  %s\n", rp.Count, rp.Duration, rp.Rule)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,140 @@
+package shred
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity is the severity of a diagnostic.
+type Severity byte
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+// String returns the label of the severity.
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiBlue   = "\x1b[1;34m"
+)
+
+// Renderer renders diagnostics for terminals in the style of compilers:
+//
+//	error[S0001]: unexpected '+', expected '(' or an identifier
+//	 --> input:1:5
+//	  |
+//	1 | a + + b
+//	  |     ^
+type Renderer struct {
+	Color    bool     // use ANSI colours
+	Width    int      // wrap messages to this width if positive
+	Filename string   // name of the source shown before positions
+	Catalog  *Catalog // message templates, may be nil
+}
+
+func (r *Renderer) paint(color, s string) string {
+	if !r.Color {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// wrap breaks a message into lines of at most r.Width characters, indenting continuation lines.
+func (r *Renderer) wrap(msg string, indent int) string {
+	if r.Width <= 0 {
+		return msg
+	}
+	var lines []string
+	line := ""
+	for _, w := range strings.Fields(msg) {
+		if line != "" && indent+len(line)+1+len(w) > r.Width {
+			lines = append(lines, line)
+			line = w
+		} else if line == "" {
+			line = w
+		} else {
+			line += " " + w
+		}
+	}
+	lines = append(lines, line)
+	return strings.Join(lines, "\n"+strings.Repeat(" ", indent))
+}
+
+func (r *Renderer) header(sev Severity, code Code, msg string) string {
+	label := sev.String()
+	if code != "" {
+		label += "[" + string(code) + "]"
+	}
+	color := ansiRed
+	if sev == SeverityWarning {
+		color = ansiYellow
+	}
+	return r.paint(color, label) + r.paint(ansiBold, ": "+r.wrap(msg, len(label)+2))
+}
+
+// excerpt renders the location and source line of a position with a marker under it.
+func (r *Renderer) excerpt(src string, pos Position, width int) string {
+	loc := pos.String()
+	if r.Filename != "" {
+		loc = r.Filename + ":" + loc
+	}
+	line, ok := NewSourceIndex(src).LineText(pos.Line)
+	num := fmt.Sprint(pos.Line)
+	pad := strings.Repeat(" ", len(num))
+	ret := "\n" + pad + r.paint(ansiBlue, "--> ") + loc
+	if !ok {
+		return ret
+	}
+	bar := r.paint(ansiBlue, " |")
+	ret += "\n" + pad + bar
+	ret += "\n" + r.paint(ansiBlue, num+" |") + " " + line
+	ret += "\n" + pad + bar + " " + r.paint(ansiRed, caret(line, pos.Column, width))
+	return ret
+}
+
+// Error renders an error. Parse errors are shown with an excerpt of the source,
+// conflict errors as one diagnostic per conflict. A nil error renders as an empty string.
+func (r *Renderer) Error(src string, err error) string {
+	if err == nil {
+		return ""
+	}
+	var cerr *ConflictError
+	if errors.As(err, &cerr) {
+		ds := make([]string, len(cerr.Conflicts))
+		for i, c := range cerr.Conflicts {
+			ds[i] = r.header(SeverityError, c.Code(), c.String())
+		}
+		return strings.Join(ds, "\n")
+	}
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		return r.header(SeverityError, ErrorCode(err), err.Error())
+	}
+	msg := perr.Err.Error()
+	if r.Catalog != nil {
+		msg = strings.TrimPrefix(r.Catalog.Message(perr), perr.Pos.String()+": ")
+	}
+	return r.header(SeverityError, perr.Code(), msg) + r.excerpt(src, perr.Pos, tokenWidth(perr.Token))
+}
+
+// Warning renders a grammar warning.
+func (r *Renderer) Warning(w Warning) string {
+	return r.header(SeverityWarning, w.Code, w.Msg)
+}
+
+// Problem renders a problem found by Validate.
+func (r *Renderer) Problem(p Problem) string {
+	return r.header(p.Severity, p.Code, p.Msg)
+}
@@ -0,0 +1,48 @@
+package shred
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// RuneFunc is a function returning the runes of a source one by one, like ReadRune.
+// It lets sources such as ropes or gap buffers be tokenised without copying their text.
+type RuneFunc func() (r rune, size int, err error)
+
+// ReadRune calls the function.
+func (f RuneFunc) ReadRune() (rune, int, error) { return f() }
+
+// utf8Reader encodes the runes of a rune reader as UTF-8.
+type utf8Reader struct {
+	rr      io.RuneReader
+	buf     [utf8.UTFMax]byte
+	pending []byte // encoded bytes of a rune that didn't fit
+}
+
+// UTF8Reader returns a reader of the UTF-8 encoding of the runes read from a rune reader, such as
+// an io.RuneScanner or a RuneFunc. Offsets of tokens read from it count the bytes of the encoding.
+func UTF8Reader(rr io.RuneReader) io.Reader { return &utf8Reader{rr: rr} }
+
+func (r *utf8Reader) Read(p []byte) (int, error) {
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	for n < len(p) {
+		c, _, err := r.rr.ReadRune()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				err = nil
+			}
+			return n, err
+		}
+		m := utf8.EncodeRune(r.buf[:], c)
+		k := copy(p[n:], r.buf[:m])
+		r.pending = r.buf[k:m]
+		n += k
+	}
+	return n, nil
+}
+
+// TokeniseRunes tokenises the runes read from a rune reader, such as an io.RuneScanner or a RuneFunc.
+func (tz *Tokeniser) TokeniseRunes(rr io.RuneReader) []Token {
+	return tz.Tokenise(UTF8Reader(rr))
+}
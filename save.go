@@ -0,0 +1,297 @@
+package shred
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// tablesVersion is the version of the format of saved tables. It changes whenever
+// the meaning of the saved data does.
+const tablesVersion = 2
+
+// savedTables is the serialised form of the automaton of a grammar.
+type savedTables struct {
+	Version     int
+	Fingerprint string // fingerprint of the rules and mode
+	Mode        Mode
+	Rules       []string // rules the tables were built for
+	Terms       []savedTerm
+	States      [][]int32 // rule numbers and dots of the states' items
+	Defaults    []int32
+	ActBase     []int32
+	ActNext     []int32
+	ActCheck    []int32
+	GotoBase    []int32
+	GotoNext    []int32
+	GotoCheck   []int32
+	Nts         []string
+	Conflicts   [][]int32 // state numbers followed by the numbers of the conflicting rules
+	ShiftReduce int
+	Operators   bool // whether the rules include those of operators, whose binding powers aren't saved
+}
+
+type savedTerm struct {
+	Kind Kind
+	Text string
+}
+
+// SaveTables writes the automaton of a built grammar so that it can be loaded by LoadTables
+// without building it again. The tables are tagged with the version of their format and
+// the fingerprint of the grammar.
+func (gr *Grammar) SaveTables(w io.Writer) error {
+	a, err := gr.built()
+	if err != nil {
+		return err
+	}
+	st := savedTables{
+		Version:     tablesVersion,
+		Fingerprint: fingerprint(a.rules, a.mode),
+		Mode:        a.mode,
+		Defaults:    a.defaults,
+		ActBase:     a.actBase,
+		ActNext:     a.actNext,
+		ActCheck:    a.actCheck,
+		GotoBase:    a.gotoBase,
+		GotoNext:    a.gotoNext,
+		GotoCheck:   a.gotoCheck,
+		Nts:         a.nts,
+		ShiftReduce: a.shiftReduce,
+	}
+	for _, r := range a.rules {
+		st.Rules = append(st.Rules, r.String())
+		st.Operators = st.Operators || r.prec != nil
+	}
+	for _, t := range a.terms {
+		var text string
+		if m, ok := t.(Match); ok {
+			text = m.Text
+		}
+		st.Terms = append(st.Terms, savedTerm{t.Kind(), text})
+	}
+	states := make(map[string]int32, len(a.states))
+	for _, s := range a.states {
+		states[a.stateAsString(s)] = int32(s.id)
+		items := make([]int32, 0, 2*len(s.items))
+		for _, it := range s.items {
+			items = append(items, int32(it.rule), int32(it.dot))
+		}
+		st.States = append(st.States, items)
+	}
+	nums := a.ruleNumbers()
+	for _, c := range a.conflicts {
+		ids := []int32{states[c.State]}
+		for _, r := range c.Rules {
+			ids = append(ids, int32(nums[r]))
+		}
+		st.Conflicts = append(st.Conflicts, ids)
+	}
+	return gob.NewEncoder(w).Encode(&st)
+}
+
+// Fingerprint returns a hash of the rules and the mode of the grammar, which determine its automaton.
+// Saved tables and generated parsers record the fingerprint of their grammar.
+func (gr *Grammar) Fingerprint() string { return fingerprint(gr.Rules, gr.Mode) }
+
+// fingerprint returns the hex-encoded SHA-256 hash of rules and a mode.
+func fingerprint(rules []*Rule, mode Mode) string {
+	h := sha256.New()
+	fmt.Fprintln(h, mode)
+	for _, r := range rules {
+		fmt.Fprintln(h, r)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LoadTables reads an automaton written by SaveTables instead of building it.
+// The grammar must have the same rules and mode as the one whose tables were saved, only the builders
+// may differ. The tables record the version of their format and the fingerprint of their grammar, and
+// stale tables are refused with an error wrapping ErrStaleTables. Like Build, it reports conflicts
+// in a ConflictError.
+func (gr *Grammar) LoadTables(r io.Reader) error {
+	var st savedTables
+	if err := gob.NewDecoder(r).Decode(&st); err != nil {
+		return corrupted("%v", err)
+	}
+	return gr.loadTables(&st)
+}
+
+// FromTables creates a grammar from tables written by SaveTables without its rules being defined
+// in Go or built, e.g. from tables embedded in a binary. The rules are restored from the tables.
+// Builders are looked up by the names of the rules' builders in the Builders struct of WriteGo,
+// such as E_1 for the first rule for E, and rules without them produce Nodes.
+// Tables of grammars with expressions are refused since they don't record binding powers.
+func FromTables(data []byte, builders Registry) (*Grammar, error) {
+	var st savedTables
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&st); err != nil {
+		return nil, corrupted("%v", err)
+	}
+	if err := checkVersion(&st); err != nil {
+		return nil, err
+	}
+	if st.Operators {
+		return nil, newError(ErrUnsupported, "tables of a grammar with operator rules: load them into the grammar with LoadTables")
+	}
+	gr := NewGrammar(nil)
+	gr.Mode = st.Mode
+	for _, text := range st.Rules {
+		rules, err := ParseRules(text)
+		if err != nil || len(rules) != 1 {
+			return nil, corrupted("invalid rule %s", text)
+		}
+		gr.Rules = append(gr.Rules, rules[0])
+	}
+	known := make(map[string]bool, len(gr.Rules))
+	for i, name := range builderNames(gr.Rules) {
+		known[name] = true
+		if b, ok := builders[name]; ok {
+			gr.Rules[i].Reduce = b
+		}
+	}
+	names := make([]string, 0, len(builders))
+	for name := range builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			return nil, newError(ErrUnknownBuilder, "no rule for builder "+name)
+		}
+	}
+	if err := gr.loadTables(&st); err != nil {
+		return nil, err
+	}
+	return gr, nil
+}
+
+// checkVersion checks that decoded tables have the current format.
+func checkVersion(st *savedTables) error {
+	if st.Version != tablesVersion {
+		return newError(ErrStaleTables, fmt.Sprintf("tables have format version %d instead of %d", st.Version, tablesVersion))
+	}
+	return nil
+}
+
+// loadTables sets the automaton from decoded tables. Tables of another format, rules or mode
+// are refused with an error wrapping ErrStaleTables.
+func (gr *Grammar) loadTables(st *savedTables) error {
+	if err := checkVersion(st); err != nil {
+		return err
+	}
+	if len(st.Rules) != len(gr.Rules) {
+		return newError(ErrStaleTables, "tables were built for different rules")
+	}
+	for i, r := range gr.Rules {
+		if r.String() != st.Rules[i] {
+			return newError(ErrStaleTables, "tables were built for different rules: "+st.Rules[i]+" instead of "+r.String())
+		}
+	}
+	if st.Mode != gr.Mode {
+		return newError(ErrStaleTables, "tables were built in mode "+st.Mode.String()+" instead of "+gr.Mode.String())
+	}
+	if fp := gr.Fingerprint(); st.Fingerprint != fp {
+		return newError(ErrStaleTables, "tables were built for a grammar with fingerprint "+st.Fingerprint+" instead of "+fp)
+	}
+	if len(st.States) == 0 || len(st.Defaults) != len(st.States) || len(st.ActBase) != len(st.States) ||
+		len(st.GotoBase) != len(st.Nts) || len(st.ActNext) != len(st.ActCheck) || len(st.GotoNext) != len(st.GotoCheck) {
+		return corrupted("tables of %d states have inconsistent lengths", len(st.States))
+	}
+	a := &automaton{rules: append([]*Rule(nil), gr.Rules...), mode: st.Mode}
+	a.intern()
+	if len(st.Terms) != len(a.terms) || len(st.Nts) != len(a.nts) {
+		return corrupted("%d terminals and %d non-terminals instead of %d and %d", len(st.Terms), len(st.Nts), len(a.terms), len(a.nts))
+	}
+	for i, t := range a.terms {
+		if st.Terms[i].Kind != t.Kind() || t.Kind() == KindMatch && st.Terms[i].Text != t.(Match).Text {
+			return corrupted("terminal %d is not %s", i, t)
+		}
+	}
+	for i, nt := range a.nts {
+		if st.Nts[i] != nt {
+			return corrupted("non-terminal %d is %s instead of %s", i, st.Nts[i], nt)
+		}
+	}
+	for _, acts := range [][]int32{st.Defaults, st.ActNext} {
+		for _, act := range acts {
+			if int(act) > len(st.States) || int(-act) > len(gr.Rules) {
+				return corrupted("action %d out of range", act)
+			}
+		}
+	}
+	for _, g := range st.GotoNext {
+		if g < 0 || int(g) > len(st.States) {
+			return corrupted("goto %d out of range", g)
+		}
+	}
+	if !validBases(st.ActBase, len(st.ActNext), len(a.terms)) {
+		return corrupted("base of actions out of range")
+	}
+	if !validBases(st.GotoBase, len(st.GotoNext), len(st.States)) {
+		return corrupted("base of gotos out of range")
+	}
+	a.states = make([]*state, len(st.States))
+	for i, items := range st.States {
+		if len(items)%2 != 0 {
+			return corrupted("state %d has an incomplete item", i)
+		}
+		s := &state{id: i, rule: -1}
+		for j := 0; j < len(items); j += 2 {
+			r, dot := int(items[j]), int(items[j+1])
+			if r < 0 || r >= len(gr.Rules) {
+				return corrupted("rule %d of an item of state %d out of range", r, i)
+			}
+			if dot < 0 || dot > len(gr.Rules[r].Rhs) {
+				return corrupted("dot %d of an item of state %d out of range", dot, i)
+			}
+			s.items = append(s.items, item{r, dot})
+		}
+		a.states[i] = s
+	}
+	for i, c := range st.Conflicts {
+		if len(c) == 0 || c[0] < 0 || int(c[0]) >= len(st.States) {
+			return corrupted("state of conflict %d missing or out of range", i)
+		}
+		for _, r := range c[1:] {
+			if r < 0 || int(r) >= len(gr.Rules) {
+				return corrupted("rule %d of conflict %d out of range", r, i)
+			}
+		}
+	}
+	a.defaults, a.actBase, a.actNext, a.actCheck = st.Defaults, st.ActBase, st.ActNext, st.ActCheck
+	a.gotoBase, a.gotoNext, a.gotoCheck = st.GotoBase, st.GotoNext, st.GotoCheck
+	a.initState = a.states[0]
+	a.indexOperators()
+	a.conflicts, a.shiftReduce = nil, st.ShiftReduce
+	for _, c := range st.Conflicts {
+		var rs []*Rule
+		for _, r := range c[1:] {
+			rs = append(rs, a.rules[r])
+		}
+		a.conflicts = append(a.conflicts, Conflict{a.stateAsString(a.states[c[0]]), rs})
+	}
+	gr.setAutomaton(a)
+	if len(a.conflicts) > 0 {
+		return &ConflictError{a.conflicts}
+	}
+	return nil
+}
+
+// corrupted returns an error about inconsistent tables.
+func corrupted(format string, args ...interface{}) error {
+	return newError(ErrCorruptTables, "corrupted tables: "+fmt.Sprintf(format, args...))
+}
+
+// validBases reports whether the bases of packed vectors are emptyBase or could be assigned
+// by pack to vectors with indices below width in a comb vector of length n.
+func validBases(bases []int32, n, width int) bool {
+	for _, b := range bases {
+		if b != emptyBase && (int(b) <= -width || int(b) >= n) {
+			return false
+		}
+	}
+	return true
+}
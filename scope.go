@@ -0,0 +1,146 @@
+package shred
+
+// Scopes declare the symbol table the parser maintains while calling the rules' builders.
+// Rules are named by their left-hand sides or as "Lhs -> rhs" in the form of Rule.String.
+// A reduction of a rule in Open opens a scope nested in the current one and a reduction
+// of a rule in Close closes the current scope. As rules are reduced after their right-hand
+// sides, scopes are opened by marker non-terminals with empty rules placed where they begin,
+// e.g. Block -> "{" Open Stmts "}" with Open -> . Declare and Refer map rules to the positions
+// of the identifiers on their right-hand sides, counting from 0, that declare names
+// and refer to them.
+//
+// At a reduction, the references are resolved, the builder is called with the current scope
+// in Reduction.Scope, the scope is closed, the names are declared with the built value in
+// the scope that's then current and a scope is opened, each if the rule says so. A rule can
+// thus declare a function's name in the enclosing scope and open the scope of its body.
+// If Strict is set, references to undeclared names fail the parse with ErrUndeclared and
+// declarations of names already declared in the same scope with ErrRedeclared; otherwise
+// a declaration replaces the previous one and unresolved references are ignored.
+// Engines don't maintain scopes.
+type Scopes struct {
+	Open    []string
+	Close   []string
+	Declare map[string][]int
+	Refer   map[string][]int
+	Strict  bool
+}
+
+// Scope is a scope of the symbol table. The builders of the start symbol see the global scope.
+type Scope struct {
+	Parent *Scope // enclosing scope or nil
+	names  map[string]*Decl
+	decls  []*Decl
+}
+
+// Decl is a declaration of a name.
+type Decl struct {
+	Name  string
+	Token Token       // the identifier declaring the name
+	Value interface{} // value built by the declaring rule
+	Refs  []Token     // identifiers referring to the declaration
+}
+
+// Lookup finds the declaration of a name in the scope or the enclosing ones.
+func (s *Scope) Lookup(name string) (*Decl, bool) {
+	for ; s != nil; s = s.Parent {
+		if d, ok := s.names[name]; ok {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// Local finds the declaration of a name in the scope itself.
+func (s *Scope) Local(name string) (*Decl, bool) {
+	d, ok := s.names[name]
+	return d, ok
+}
+
+// Decls returns the declarations of the scope in the order they were made, including
+// those replaced by later ones.
+func (s *Scope) Decls() []*Decl { return s.decls }
+
+func (s *Scope) declare(d *Decl) {
+	if s.names == nil {
+		s.names = make(map[string]*Decl)
+	}
+	s.names[d.Name] = d
+	s.decls = append(s.decls, d)
+}
+
+// scopeAction is what a rule does to the symbol table.
+type scopeAction struct {
+	open, close    bool
+	declare, refer []int
+}
+
+// symbolTable is the symbol table of a parse.
+type symbolTable struct {
+	actions map[*Rule]*scopeAction
+	current *Scope
+	strict  bool
+}
+
+// newTable creates the symbol table of a parse with a grammar's rules.
+func (sc *Scopes) newTable(rules []*Rule) *symbolTable {
+	open, close := make(map[string]bool, len(sc.Open)), make(map[string]bool, len(sc.Close))
+	for _, name := range sc.Open {
+		open[name] = true
+	}
+	for _, name := range sc.Close {
+		close[name] = true
+	}
+	t := &symbolTable{actions: make(map[*Rule]*scopeAction), current: new(Scope), strict: sc.Strict}
+	for _, r := range rules {
+		key := r.String()
+		act := &scopeAction{
+			open:    open[r.Lhs] || open[key],
+			close:   close[r.Lhs] || close[key],
+			declare: concatInts(sc.Declare[r.Lhs], sc.Declare[key]),
+			refer:   concatInts(sc.Refer[r.Lhs], sc.Refer[key]),
+		}
+		if act.open || act.close || len(act.declare) > 0 || len(act.refer) > 0 {
+			t.actions[r] = act
+		}
+	}
+	return t
+}
+
+func concatInts(a, b []int) []int { return append(append([]int(nil), a...), b...) }
+
+// resolve resolves the references of a reduction.
+func (t *symbolTable) resolve(act *scopeAction, args []interface{}) error {
+	for _, i := range act.refer {
+		tok, err := AsToken(args[i])
+		if err != nil {
+			return err
+		}
+		if d, ok := t.current.Lookup(tok.Text()); ok {
+			d.Refs = append(d.Refs, tok)
+		} else if t.strict {
+			return &ParseError{Pos: tok.Pos(), Err: newError(ErrUndeclared, "undeclared name "+tok.Text()), Token: tok}
+		}
+	}
+	return nil
+}
+
+// reduced closes, declares and opens after the builder of a reduction has built a value.
+func (t *symbolTable) reduced(act *scopeAction, args []interface{}, v interface{}) error {
+	if act.close && t.current.Parent != nil {
+		t.current = t.current.Parent
+	}
+	for _, i := range act.declare {
+		tok, err := AsToken(args[i])
+		if err != nil {
+			return err
+		}
+		if _, ok := t.current.Local(tok.Text()); ok && t.strict {
+			return &ParseError{Pos: tok.Pos(), Err: newError(ErrRedeclared, tok.Text()+" redeclared in this scope"), Token: tok}
+		}
+		t.current.declare(&Decl{Name: tok.Text(), Token: tok, Value: v})
+	}
+	if act.open {
+		t.current = &Scope{Parent: t.current}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package shred
+
+// Script is a recorded parse whose values are built on demand.
+// It consists of steps that shift tokens or reduce rules in postfix order.
+type Script struct {
+	gr     *Grammar
+	tokens []Token
+	steps  []step
+}
+
+type step struct {
+	rule        *Rule // nil for shifts
+	first, last int   // range of covered tokens
+	start       int   // index of the first step of the subtree
+}
+
+// Step is a step of a script.
+type Step struct {
+	Rule  *Rule // reduced rule or nil if the step is a shift
+	Token Token // shifted token or nil if the step is a reduction
+	Span  Span  // source range covered by the step
+}
+
+// scriptBuilder records the steps of a parse. The value of a symbol is the index of its step.
+type scriptBuilder struct {
+	s *Script
+}
+
+func (b scriptBuilder) shift(tokens []Token, i int) (interface{}, error) {
+	b.s.steps = append(b.s.steps, step{nil, i, i + 1, len(b.s.steps)})
+	return len(b.s.steps) - 1, nil
+}
+
+func (b scriptBuilder) reduce(r *Rule, args []interface{}, tokens []Token, first, last int) (interface{}, error) {
+	start := len(b.s.steps)
+	if len(args) > 0 {
+		start = b.s.steps[args[0].(int)].start
+	}
+	b.s.steps = append(b.s.steps, step{r, first, last, start})
+	return len(b.s.steps) - 1, nil
+}
+
+// ParseScript parses a sequence of tokens recording the parse without calling any builders.
+// Scripts have no steps for placeholders, so tokens with placeholders are refused.
+func (gr *Grammar) ParseScript(tokens []Token) (*Script, error) {
+	for _, tok := range tokens {
+		if tok.Kind() == KindPlaceholder {
+			return nil, positioned(newError(ErrUnexpectedToken, "placeholders can't be recorded in scripts"), tok)
+		}
+	}
+	s := &Script{gr: gr, tokens: tokens}
+	if _, err := gr.parse(tokens, scriptBuilder{s}, nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Len returns the number of steps.
+func (s *Script) Len() int { return len(s.steps) }
+
+// At returns the i-th step.
+func (s *Script) At(i int) Step {
+	st := s.steps[i]
+	ret := Step{Rule: st.rule, Span: spanOf(s.tokens, st.first, st.last)}
+	if st.rule == nil {
+		ret.Token = s.tokens[st.first]
+	}
+	return ret
+}
+
+// Run builds the value of the whole parse.
+func (s *Script) Run(env interface{}) (interface{}, error) {
+	return s.Build(len(s.steps)-1, env)
+}
+
+// Build builds the value of the subtree whose last step is the i-th one.
+// Only the builders of the rules reduced in the subtree are called.
+func (s *Script) Build(i int, env interface{}) (interface{}, error) {
+	b := s.gr.values(env)
+	var stack []interface{}
+	for _, st := range s.steps[s.steps[i].start : i+1] {
+		if st.rule == nil {
+			stack = append(stack, s.tokens[st.first])
+			continue
+		}
+		l := len(st.rule.Rhs)
+		v, err := b.reduce(st.rule, stack[len(stack)-l:], s.tokens, st.first, st.last)
+		if err != nil {
+			return nil, positioned(err, s.tokens[st.first])
+		}
+		stack = append(stack[:len(stack)-l], v)
+	}
+	return stack[0], nil
+}
@@ -0,0 +1,49 @@
+package shred
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sexpr renders a parse result as an S-expression.
+// Nodes are rendered as (lhs child ...), error nodes as (error token ...), slices of values as (value ...) and tokens as in the source.
+func Sexpr(v interface{}) string {
+	var sb strings.Builder
+	writeSexpr(&sb, v)
+	return sb.String()
+}
+
+func writeSexpr(sb *strings.Builder, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		sb.WriteString("nil")
+	case *Node:
+		sb.WriteString("(" + v.rule.Lhs)
+		for _, c := range v.children {
+			sb.WriteByte(' ')
+			writeSexpr(sb, c)
+		}
+		sb.WriteByte(')')
+	case *ErrorNode:
+		sb.WriteString("(error")
+		for _, t := range v.tokens {
+			sb.WriteString(" " + t.Raw())
+		}
+		sb.WriteByte(')')
+	case Token:
+		sb.WriteString(v.Raw())
+	case []interface{}:
+		sb.WriteByte('(')
+		for i, c := range v {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			writeSexpr(sb, c)
+		}
+		sb.WriteByte(')')
+	case string:
+		fmt.Fprintf(sb, "%q", v)
+	default:
+		fmt.Fprint(sb, v)
+	}
+}
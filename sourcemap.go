@@ -0,0 +1,93 @@
+package shred
+
+import "reflect"
+
+// SourceMap maps the values of a parse to the tokens they cover, so that tools can find
+// the source of values whose builders don't record their positions. Tokens map to themselves
+// and the values built by rules to the tokens of the rules; values passed through by rules
+// keep the tokens of the rule that built them. Values are identified by equality except
+// for slices and maps, which are identified by their contents' addresses and lengths,
+// so equal values, such as equal strings, share the tokens of the first of them.
+// Empty slices and maps, structs, arrays and nil aren't mapped.
+type SourceMap struct {
+	tokens []Token
+	ranges map[interface{}]tokenRange
+	keep   []interface{} // mapped slices and maps, whose addresses mustn't be reused
+}
+
+// sliceKey identifies a slice or a map.
+type sliceKey struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// sourceKey returns the key of a value in a source map.
+func sourceKey(v interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		if rv.Len() == 0 {
+			return nil, false
+		}
+		return sliceKey{rv.Type(), rv.Pointer(), rv.Len()}, true
+	case reflect.Struct, reflect.Array, reflect.Func:
+		return nil, false
+	}
+	return v, true
+}
+
+// add maps a value to tokens[first:last] unless it's mapped.
+func (m *SourceMap) add(v interface{}, first, last int) {
+	if key, ok := sourceKey(v); ok {
+		if _, ok := m.ranges[key]; !ok {
+			m.ranges[key] = tokenRange{first, last}
+			if _, ok := key.(sliceKey); ok {
+				m.keep = append(m.keep, v)
+			}
+		}
+	}
+}
+
+// Range returns the indices of the tokens covered by a value, tokens[first:last].
+func (m *SourceMap) Range(v interface{}) (first, last int, ok bool) {
+	key, ok := sourceKey(v)
+	if !ok {
+		return 0, 0, false
+	}
+	r, ok := m.ranges[key]
+	return r.first, r.last, ok
+}
+
+// Tokens returns the tokens covered by a value, including any trivia between them.
+func (m *SourceMap) Tokens(v interface{}) ([]Token, bool) {
+	first, last, ok := m.Range(v)
+	if !ok {
+		return nil, false
+	}
+	return m.tokens[first:last], true
+}
+
+// Span returns the source range covered by a value.
+func (m *SourceMap) Span(v interface{}) (Span, bool) {
+	first, last, ok := m.Range(v)
+	if !ok {
+		return Span{}, false
+	}
+	return spanOf(m.tokens, first, last), true
+}
+
+// Len returns the number of mapped values.
+func (m *SourceMap) Len() int { return len(m.ranges) }
+
+// ParseMapped parses a sequence of tokens and returns the result with a source map of its values.
+// If the parse fails, the map has the values built before the error.
+func (gr *Grammar) ParseMapped(tokens []Token) (interface{}, *SourceMap, error) {
+	vb := gr.values(nil)
+	vb.smap = &SourceMap{tokens: tokens, ranges: make(map[interface{}]tokenRange)}
+	v, err := gr.parseValues(tokens, vb, nil)
+	return v, vb.smap, err
+}
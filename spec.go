@@ -0,0 +1,194 @@
+package shred
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a declarative definition of a grammar and its tokeniser, usually read from YAML
+// by ReadSpec:
+//
+//	mode: slr
+//	lexer:
+//	  keywords: [let, in]
+//	rules:
+//	  - 0 -> Expr
+//	  - rule: Atom -> "let" _ident_ "=" Expr "in" Expr
+//	    builder: let
+//	  - Atom -> _ident_ | "(" Expr ")"
+//	operators:
+//	  - lhs: Expr
+//	    operand: Atom
+//	    levels:
+//	      - {assoc: left, ops: ["+", "-"], builder: binary}
+//	      - {assoc: left, ops: ["*", "/"], builder: binary}
+//
+// Rules are written in the textual format of ParseRules. Builders are named and resolved
+// in a Registry when the grammar is created.
+type Spec struct {
+	Mode      string          `yaml:"mode"`      // lr0, slr or lr1
+	Lexer     LexerSpec       `yaml:"lexer"`     // options of the tokeniser
+	Rules     []RuleSpec      `yaml:"rules"`     // rules in the textual format
+	Operators []OperatorsSpec `yaml:"operators"` // binary operators expanded to rules
+}
+
+// LexerSpec are the options of the tokeniser of a Spec.
+type LexerSpec struct {
+	Keywords       []string `yaml:"keywords"`
+	KeepWhitespace bool     `yaml:"keep_whitespace"`
+	NestedComments bool     `yaml:"nested_comments"`
+	Shebang        bool     `yaml:"shebang"`
+}
+
+// RuleSpec is one or more rules with the same builder and tag.
+// In YAML, it's either a string with the rules or a mapping with the fields.
+type RuleSpec struct {
+	Rule    string `yaml:"rule"`
+	Builder string `yaml:"builder"`
+	Tag     string `yaml:"tag"`
+}
+
+// UnmarshalYAML decodes a rule spec from a string or a mapping.
+func (rs *RuleSpec) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*rs = RuleSpec{Rule: n.Value}
+		return nil
+	}
+	type plain RuleSpec
+	return n.Decode((*plain)(rs))
+}
+
+// OperatorsSpec defines binary operators by precedence levels, the loosest binding one first.
+// Every level gets its own non-terminal: the first one is Lhs, the following ones are named
+// Lhs_1, Lhs_2 and so on, and the operands of the last level are Operand. The operator rules
+// have three symbols on their right-hand sides and every level has a rule passing the operand
+// of the next one through, e.g. Lhs -> Lhs_1.
+type OperatorsSpec struct {
+	Lhs     string      `yaml:"lhs"`
+	Operand string      `yaml:"operand"`
+	Levels  []LevelSpec `yaml:"levels"`
+}
+
+// LevelSpec is a precedence level of binary operators.
+// Assoc is left (the default), right or none.
+type LevelSpec struct {
+	Assoc   string   `yaml:"assoc"`
+	Ops     []string `yaml:"ops"`
+	Builder string   `yaml:"builder"`
+	Tag     string   `yaml:"tag"`
+}
+
+// Registry maps the names of builders used by specs to builders.
+type Registry map[string]func(*Reduction) (interface{}, error)
+
+// ParseMode returns the mode with the given name.
+func ParseMode(name string) (Mode, error) {
+	for _, m := range []Mode{ModeLR0, ModeSLR, ModeLR1} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, newError(ErrGrammarSyntax, "unknown mode "+strconv.Quote(name))
+}
+
+// ReadSpec reads a spec from a YAML document. Unknown fields are errors.
+func ReadSpec(r io.Reader) (*Spec, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var spec Spec
+	if err := dec.Decode(&spec); err != nil {
+		return nil, newError(ErrGrammarSyntax, err.Error())
+	}
+	return &spec, nil
+}
+
+// Grammar creates the grammar of a spec with builders from a registry and the spec's tokeniser.
+// It isn't built.
+func (spec *Spec) Grammar(reg Registry) (*Grammar, error) {
+	gr := NewGrammar(nil)
+	gr.Tokeniser = spec.Tokeniser()
+	if spec.Mode != "" {
+		m, err := ParseMode(spec.Mode)
+		if err != nil {
+			return nil, err
+		}
+		gr.Mode = m
+	}
+	add := func(rules []*Rule, builder, tag string) error {
+		var b func(*Reduction) (interface{}, error)
+		if builder != "" {
+			if b = reg[builder]; b == nil {
+				return newError(ErrUnknownBuilder, "unknown builder "+strconv.Quote(builder))
+			}
+		}
+		for _, r := range rules {
+			r.Reduce = b
+			if tag != "" {
+				r.Tag = tag
+			}
+			gr.Rules = append(gr.Rules, r)
+		}
+		return nil
+	}
+	for i, rs := range spec.Rules {
+		rules, err := ParseRules(rs.Rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		if err := add(rules, rs.Builder, rs.Tag); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	for _, ops := range spec.Operators {
+		for i, lv := range ops.Levels {
+			lhs, next := levelName(ops.Lhs, i), levelName(ops.Lhs, i+1)
+			if i == len(ops.Levels)-1 {
+				next = ops.Operand
+			}
+			left, right := NonTerminal{lhs}, NonTerminal{next}
+			switch lv.Assoc {
+			case "", "left":
+			case "right":
+				left, right = right, left
+			case "none":
+				left = right
+			default:
+				return nil, newError(ErrGrammarSyntax, "unknown associativity "+strconv.Quote(lv.Assoc)+" of operators of "+ops.Lhs)
+			}
+			rules := []*Rule{{Lhs: lhs, Rhs: []Symbol{NonTerminal{next}}, Builder: func(args []interface{}) interface{} {
+				return args[0]
+			}}}
+			for _, op := range lv.Ops {
+				rules = append(rules, &Rule{Lhs: lhs, Rhs: []Symbol{left, Match{op}, right}})
+			}
+			if err := add(rules[:1], "", ""); err != nil {
+				return nil, err
+			}
+			if err := add(rules[1:], lv.Builder, lv.Tag); err != nil {
+				return nil, fmt.Errorf("operators of %s: %w", ops.Lhs, err)
+			}
+		}
+	}
+	return gr, nil
+}
+
+// levelName returns the name of the non-terminal of a precedence level.
+func levelName(lhs string, level int) string {
+	if level == 0 {
+		return lhs
+	}
+	return lhs + "_" + strconv.Itoa(level)
+}
+
+// Tokeniser creates the tokeniser of a spec.
+func (spec *Spec) Tokeniser() *Tokeniser {
+	return &Tokeniser{
+		Keywords:       spec.Lexer.Keywords,
+		KeepWhitespace: spec.Lexer.KeepWhitespace,
+		NestedComments: spec.Lexer.NestedComments,
+		Shebang:        spec.Lexer.Shebang,
+	}
+}
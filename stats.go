@@ -0,0 +1,49 @@
+package shred
+
+import "unsafe"
+
+// Stats describes the size of a grammar and its parse tables.
+type Stats struct {
+	Rules         int
+	Terminals     int
+	NonTerminals  int
+	States        int
+	Items         int // items in all states
+	ActionEntries int
+	GotoEntries   int
+	ShiftReduce   int // shift/reduce conflicts resolved in favour of shifting
+	Conflicts     int // unresolved reduce/reduce conflicts
+	TableBytes    int // rough estimate of the memory used by the tables
+}
+
+// Stats returns statistics of a built grammar. They are zero if the grammar isn't built.
+func (gr *Grammar) Stats() Stats {
+	a, _ := gr.current()
+	if a == nil {
+		return Stats{}
+	}
+	st := Stats{
+		Rules:        len(a.rules),
+		Terminals:    len(a.terminals),
+		NonTerminals: len(a.nonterminals),
+		States:       len(a.states),
+		ShiftReduce:  a.shiftReduce,
+		Conflicts:    len(a.conflicts),
+	}
+	const (
+		entry     = int(unsafe.Sizeof(int32(0)))             // entry of a dense table
+		mapEntry  = 2 * int(unsafe.Sizeof(interface{}(nil))) // key and value of a map
+		stateSize = int(unsafe.Sizeof(state{}))
+		itemSize  = int(unsafe.Sizeof(item{}))
+	)
+	for _, s := range a.states {
+		_, as := a.stateActions(s)
+		_, gt := a.stateGotos(s)
+		st.Items += len(s.items)
+		st.ActionEntries += len(as)
+		st.GotoEntries += len(gt)
+	}
+	st.TableBytes = st.States*stateSize + st.Items*itemSize + len(a.matchIDs)*mapEntry +
+		(len(a.defaults)+len(a.actBase)+2*len(a.actNext)+len(a.gotoBase)+2*len(a.gotoNext)+len(a.ruleLhs))*entry
+	return st
+}
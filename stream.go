@@ -0,0 +1,250 @@
+package shred
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/scanner"
+	"unicode"
+)
+
+// TokenStream is a sequence of tokens that supports backtracking.
+type TokenStream interface {
+	// Next returns the next token. At the end of the stream it keeps returning the EOF token.
+	Next() Token
+	// Peek returns the next token without consuming it.
+	Peek() Token
+	// Mark returns the current position in the stream.
+	Mark() int
+	// Rewind moves back to a position returned by Mark and releases the mark.
+	Rewind(mark int)
+	// Release releases a mark without moving back.
+	Release(mark int)
+}
+
+// Cursor is a token stream over a slice of tokens.
+type Cursor struct {
+	tokens []Token
+	pos    int
+}
+
+// NewCursor creates a cursor over tokens. The last token must be EOF.
+func NewCursor(tokens []Token) *Cursor {
+	return &Cursor{tokens: tokens}
+}
+
+// Next returns the next token.
+func (c *Cursor) Next() Token {
+	tok := c.tokens[c.pos]
+	if c.pos < len(c.tokens)-1 {
+		c.pos++
+	}
+	return tok
+}
+
+// Peek returns the next token without consuming it.
+func (c *Cursor) Peek() Token { return c.tokens[c.pos] }
+
+// Mark returns the current position.
+func (c *Cursor) Mark() int { return c.pos }
+
+// Rewind moves back to a marked position.
+func (c *Cursor) Rewind(mark int) { c.pos = mark }
+
+// Release does nothing as the cursor keeps all its tokens.
+func (c *Cursor) Release(mark int) {}
+
+// Scanner is a streaming tokeniser.
+// Tokens are only buffered while marks are held.
+type Scanner struct {
+	s        scanner.Scanner
+	keywords map[string]struct{}
+	keepWS   bool
+	nested   bool
+	shebang  bool
+	pending  *goToken
+	buf      []Token
+	base     int
+	pos      int
+	marks    int
+}
+
+// NewScanner creates a streaming tokeniser reading from a reader.
+func NewScanner(r io.Reader) *Scanner {
+	return new(Tokeniser).NewScanner(r)
+}
+
+// NewScanner creates a streaming tokeniser reading from a reader.
+func (tz *Tokeniser) NewScanner(r io.Reader) *Scanner {
+	sc := &Scanner{
+		keywords: make(map[string]struct{}, len(tz.Keywords)),
+		keepWS:   tz.KeepWhitespace,
+		nested:   tz.NestedComments,
+		shebang:  tz.Shebang,
+	}
+	for _, kw := range tz.Keywords {
+		sc.keywords[kw] = struct{}{}
+	}
+	sc.s.Init(r)
+	if tz.KeepWhitespace {
+		sc.s.Whitespace = 0
+		sc.s.Mode &^= scanner.SkipComments
+	}
+	if tz.NestedComments {
+		// comments are scanned by scanComment
+		sc.s.Mode &^= scanner.ScanComments | scanner.SkipComments
+	}
+	if tz.Placeholders {
+		sc.s.IsIdentRune = isPlaceholderRune
+	}
+	return sc
+}
+
+func (sc *Scanner) scanOne() *goToken {
+	if tok := sc.pending; tok != nil {
+		sc.pending = nil
+		return tok
+	}
+	tok := sc.s.Scan()
+	if tok == '#' && sc.shebang && sc.s.Offset == 0 && sc.s.Peek() == '!' {
+		if com := sc.scanLine(); sc.keepWS {
+			return com
+		}
+		tok = sc.s.Scan()
+	}
+	for tok == '/' && sc.nested {
+		if ch := sc.s.Peek(); ch != '/' && ch != '*' {
+			break
+		}
+		if com := sc.scanComment(); sc.keepWS {
+			return com
+		}
+		tok = sc.s.Scan()
+	}
+	text := sc.s.TokenText()
+	if tok == scanner.Ident && text[0] == '$' {
+		tok = placeholder
+		if text == "$" {
+			tok = '$'
+		}
+	}
+	_, kw := sc.keywords[text]
+	return &goToken{tok, text, sc.s.Position, kw && tok == scanner.Ident}
+}
+
+// scanComment scans a comment whose / has been scanned, nesting block comments.
+// An unterminated comment is reported like by text/scanner and ends at EOF.
+func (sc *Scanner) scanComment() *goToken {
+	if sc.s.Peek() == '/' {
+		return sc.scanLine()
+	}
+	pos := sc.s.Position
+	var sb strings.Builder
+	sb.WriteRune('/')
+	sb.WriteRune(sc.s.Next())
+	for depth := 1; depth > 0; {
+		ch := sc.s.Next()
+		if ch == scanner.EOF {
+			sc.s.ErrorCount++
+			if sc.s.Error != nil {
+				sc.s.Error(&sc.s, "comment not terminated")
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: comment not terminated\n", pos)
+			}
+			break
+		}
+		sb.WriteRune(ch)
+		switch next := sc.s.Peek(); {
+		case ch == '/' && next == '*':
+			sb.WriteRune(sc.s.Next())
+			depth++
+		case ch == '*' && next == '/':
+			sb.WriteRune(sc.s.Next())
+			depth--
+		}
+	}
+	return &goToken{scanner.Comment, sb.String(), pos, false}
+}
+
+// scanLine scans the rest of a line whose first character has been scanned as a comment.
+// The line break isn't part of it.
+func (sc *Scanner) scanLine() *goToken {
+	pos := sc.s.Position
+	var sb strings.Builder
+	sb.WriteString(sc.s.TokenText())
+	for ch := sc.s.Peek(); ch != '\n' && ch != scanner.EOF; ch = sc.s.Peek() {
+		sb.WriteRune(sc.s.Next())
+	}
+	return &goToken{scanner.Comment, sb.String(), pos, false}
+}
+
+// isPlaceholderRune reports whether a character is part of an identifier, which may start with $.
+func isPlaceholderRune(ch rune, i int) bool {
+	return ch == '$' && i == 0 || ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch) && i > 0
+}
+
+func isSpace(tok rune) bool {
+	return tok >= 0 && scanner.GoWhitespace&(1<<uint(tok)) != 0
+}
+
+func (sc *Scanner) scan() Token {
+	tok := sc.scanOne()
+	if !sc.keepWS || !isSpace(tok.tok) {
+		return tok
+	}
+	tok.tok = whitespace
+	for {
+		next := sc.scanOne()
+		if !isSpace(next.tok) {
+			sc.pending = next
+			return tok
+		}
+		tok.text += next.text
+	}
+}
+
+// Next returns the next token.
+func (sc *Scanner) Next() Token {
+	tok := sc.Peek()
+	if !tok.IsEOF() {
+		sc.pos++
+	}
+	if sc.marks == 0 {
+		sc.buf = sc.buf[sc.pos-sc.base:]
+		sc.base = sc.pos
+	}
+	return tok
+}
+
+// Peek returns the next token without consuming it.
+func (sc *Scanner) Peek() Token {
+	if i := sc.pos - sc.base; i < len(sc.buf) {
+		return sc.buf[i]
+	}
+	tok := sc.scan()
+	sc.buf = append(sc.buf, tok)
+	return tok
+}
+
+// Mark returns the current position and holds it until it's released or rewound to.
+func (sc *Scanner) Mark() int {
+	sc.marks++
+	return sc.pos
+}
+
+// Rewind moves back to a marked position and releases the mark.
+func (sc *Scanner) Rewind(mark int) {
+	if mark < sc.base {
+		panic("rewinding to a released mark")
+	}
+	sc.pos = mark
+	sc.Release(mark)
+}
+
+// Release releases a mark.
+func (sc *Scanner) Release(mark int) {
+	if sc.marks > 0 {
+		sc.marks--
+	}
+}
@@ -0,0 +1,120 @@
+package shred
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// fieldMapping maps a rule's reduction to a struct field.
+type fieldMapping struct {
+	field int
+	arg   int    // index of the right-hand side symbol or -1
+	what  string // "lhs", "span" or "tokens" if arg is -1
+}
+
+// StructRule creates a rule whose builder stores the reduction in the fields of a new *T.
+// Fields are selected by the shred tag, which is either the index of a right-hand side symbol,
+// "lhs" for the rule's left-hand side, "span" for its span or "tokens" for the covered tokens.
+// Tokens are converted to strings, numbers and booleans as required by the field types.
+// StructRule panics if T isn't a struct or a tag is invalid.
+func StructRule[T any](lhs string, rhs []Symbol) *Rule {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		panic(typ.String() + " is not a struct")
+	}
+	var mappings []fieldMapping
+	for i := 0; i < typ.NumField(); i++ {
+		tag, ok := typ.Field(i).Tag.Lookup("shred")
+		if !ok || tag == "-" {
+			continue
+		}
+		switch tag {
+		case "lhs", "span", "tokens":
+			mappings = append(mappings, fieldMapping{i, -1, tag})
+		default:
+			arg, err := strconv.Atoi(tag)
+			if err != nil || arg < 0 || arg >= len(rhs) {
+				panic(fmt.Sprintf("invalid shred tag %q on field %s of %s", tag, typ.Field(i).Name, typ))
+			}
+			mappings = append(mappings, fieldMapping{i, arg, ""})
+		}
+	}
+	return &Rule{
+		Lhs: lhs,
+		Rhs: rhs,
+		Reduce: func(red *Reduction) (interface{}, error) {
+			ptr := reflect.New(typ)
+			for _, m := range mappings {
+				var v interface{}
+				switch {
+				case m.arg >= 0:
+					v = red.Args[m.arg]
+				case m.what == "lhs":
+					v = lhs
+				case m.what == "span":
+					v = red.Span
+				case m.what == "tokens":
+					v = append([]Token(nil), red.Tokens...)
+				}
+				if err := assignField(ptr.Elem().Field(m.field), v); err != nil {
+					return nil, newError(ErrResultType, fmt.Sprintf("field %s of %s: %v", typ.Field(m.field).Name, typ, err))
+				}
+			}
+			return ptr.Interface(), nil
+		},
+	}
+}
+
+// assignField sets a struct field to a value converting tokens as needed.
+func assignField(f reflect.Value, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(f.Type()) {
+		f.Set(rv)
+		return nil
+	}
+	if tok, ok := v.(Token); ok {
+		text := tok.Text()
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(text)
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(text, 0, f.Type().Bits())
+			if err != nil {
+				return err
+			}
+			f.SetInt(n)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(text, 0, f.Type().Bits())
+			if err != nil {
+				return err
+			}
+			f.SetUint(n)
+			return nil
+		case reflect.Float32, reflect.Float64:
+			x, err := strconv.ParseFloat(text, f.Type().Bits())
+			if err != nil {
+				return err
+			}
+			f.SetFloat(x)
+			return nil
+		case reflect.Bool:
+			b, err := strconv.ParseBool(text)
+			if err != nil {
+				return err
+			}
+			f.SetBool(b)
+			return nil
+		}
+	}
+	if rv.Type().ConvertibleTo(f.Type()) && rv.Kind() != reflect.String {
+		f.Set(rv.Convert(f.Type()))
+		return nil
+	}
+	return fmt.Errorf("can't assign %T to %s", v, f.Type())
+}
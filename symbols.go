@@ -0,0 +1,21 @@
+package shred
+
+// Shorthands for composing rules in Go code, e.g.
+//
+//	{Lhs: "Sum", Rhs: Seq(NT("Sum"), T("+"), NT("Product"))}
+
+// NT returns a non-terminal.
+func NT(name string) Symbol { return NonTerminal{name} }
+
+// T returns a match terminal.
+func T(text string) Symbol { return Match{text} }
+
+// ID returns the identifier terminal.
+func ID() Symbol { return Ident{} }
+
+// Kw returns a match terminal for a keyword of the tokeniser. Keywords are matched
+// like other match terminals, Kw only documents the intent.
+func Kw(text string) Symbol { return Match{text} }
+
+// Seq returns the right-hand side of a rule.
+func Seq(symbols ...Symbol) []Symbol { return symbols }
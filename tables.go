@@ -0,0 +1,230 @@
+package shred
+
+import (
+	"fmt"
+	"sort"
+)
+
+// tables are the parse tables of a built grammar. States, terminals and non-terminals are numbered.
+// Actions are encoded as ints: 0 for errors, n > 0 for shifts to state n-1 and n < 0 for reductions by rule -n-1.
+// Gotos are encoded as n > 0 for gotos to state n-1 and 0 if there's none.
+//
+// The tables are compressed. Every state has a default action, its reduction or an error,
+// and only the other actions are stored. The rows of actions of the states and the columns
+// of gotos of the non-terminals are packed into comb vectors by row displacement: an entry
+// with index i of a vector with base b is stored at b+i and the check slice holds i there.
+// Equal vectors share their entries.
+type tables struct {
+	terms     []Terminal             // terminals by number
+	matchIDs  map[string]int32       // numbers of match terminals by text
+	identID   int32                  // number of the identifier terminal or -1
+	litIDs    [KindComment + 1]int32 // numbers of the literal terminals by kind or -1
+	eofID     int32                  // number of the EOF terminal
+	nts       []string               // non-terminals by number
+	rhs       [][]int32              // right-hand sides of the rules, terminals as n and non-terminals as -n-1
+	ntRules   [][]int                // numbers of the rules of the non-terminals
+	ruleLhs   []int32                // numbers of the rules' left-hand sides
+	defaults  []int32                // default actions of the states
+	actBase   []int32                // bases of the states' rows of actions
+	actNext   []int32                // actions
+	actCheck  []int32                // terminals of the actions or -1
+	gotoBase  []int32                // bases of the non-terminals' columns of gotos
+	gotoNext  []int32                // gotos
+	gotoCheck []int32                // states of the gotos or -1
+}
+
+// intern numbers the terminals and non-terminals of the grammar in the order of their names
+// and translates the rules to symbol numbers.
+func (a *automaton) intern() {
+	a.terminals = map[Terminal]struct{}{EOF{}: {}}
+	a.nonterminals = make(map[NonTerminal]struct{})
+	ntIDs := make(map[string]int32)
+	for _, r := range a.rules {
+		ntIDs[r.Lhs] = -1
+		for _, s := range r.Rhs {
+			switch s := s.(type) {
+			case NonTerminal:
+				a.nonterminals[s] = struct{}{}
+				ntIDs[s.Name] = -1
+			case Terminal:
+				a.terminals[s] = struct{}{}
+			}
+		}
+	}
+	t := tables{matchIDs: make(map[string]int32), identID: -1}
+	for i := range t.litIDs {
+		t.litIDs[i] = -1
+	}
+	for term := range a.terminals {
+		t.terms = append(t.terms, term)
+	}
+	sort.Slice(t.terms, func(i, j int) bool { return t.terms[i].String() < t.terms[j].String() })
+	termIDs := make(map[Terminal]int32, len(t.terms))
+	for i, term := range t.terms {
+		termIDs[term] = int32(i)
+		switch term := term.(type) {
+		case Match:
+			t.matchIDs[term.Text] = int32(i)
+		case Ident:
+			t.identID = int32(i)
+		case EOF:
+			t.eofID = int32(i)
+		case Literal:
+			if isLiteralKind(Kind(term)) {
+				t.litIDs[term] = int32(i)
+			}
+		}
+	}
+	for nt := range ntIDs {
+		t.nts = append(t.nts, nt)
+	}
+	sort.Strings(t.nts)
+	for i, nt := range t.nts {
+		ntIDs[nt] = int32(i)
+	}
+	t.ntRules = make([][]int, len(t.nts))
+	t.ruleLhs = make([]int32, len(a.rules))
+	t.rhs = make([][]int32, len(a.rules))
+	for i, r := range a.rules {
+		t.ruleLhs[i] = ntIDs[r.Lhs]
+		t.ntRules[t.ruleLhs[i]] = append(t.ntRules[t.ruleLhs[i]], i)
+		t.rhs[i] = make([]int32, len(r.Rhs))
+		for j, s := range r.Rhs {
+			switch s := s.(type) {
+			case NonTerminal:
+				t.rhs[i][j] = -ntIDs[s.Name] - 1
+			case Terminal:
+				t.rhs[i][j] = termIDs[s]
+			}
+		}
+	}
+	a.tables = t
+}
+
+// buildTables fills the tables from the numbered states.
+func (a *automaton) buildTables() {
+	a.defaults = make([]int32, len(a.states))
+	rows := make([][]entry, len(a.states))
+	cols := make([][]entry, len(a.nts))
+	for _, s := range a.states {
+		if s.rule >= 0 {
+			a.defaults[s.id] = -int32(s.rule) - 1
+		}
+		rows[s.id] = append(rows[s.id], s.reduces...)
+		s.reduces = nil
+		for sym, s2 := range s.succ {
+			if sym >= 0 {
+				rows[s.id] = append(rows[s.id], entry{sym, int32(s2.id) + 1})
+			} else {
+				cols[-sym-1] = append(cols[-sym-1], entry{int32(s.id), int32(s2.id) + 1})
+			}
+		}
+		s.succ, s.la = nil, nil
+	}
+	a.actBase, a.actNext, a.actCheck = pack(rows)
+	a.gotoBase, a.gotoNext, a.gotoCheck = pack(cols)
+}
+
+// entry is an entry of a sparse vector.
+type entry struct {
+	index, value int32
+}
+
+// emptyBase is the base of empty vectors. Lookups in them never reach the comb.
+const emptyBase = -1 << 30
+
+// pack packs sparse vectors into a comb vector. Vectors are placed at the first base
+// where their entries don't collide with others, equal vectors get the same base.
+func pack(vectors [][]entry) (base, next, check []int32) {
+	base = make([]int32, len(vectors))
+	shared := make(map[string]int32)
+	used := make(map[int32]bool)
+	for v, es := range vectors {
+		if len(es) == 0 {
+			base[v] = emptyBase
+			continue
+		}
+		sort.Slice(es, func(i, j int) bool { return es[i].index < es[j].index })
+		key := fmt.Sprint(es)
+		if b, ok := shared[key]; ok {
+			base[v] = b
+			continue
+		}
+		b := -es[0].index
+	search:
+		for ; ; b++ {
+			if used[b] {
+				continue
+			}
+			for _, e := range es {
+				if i := int(b + e.index); i < len(check) && check[i] >= 0 {
+					continue search
+				}
+			}
+			break
+		}
+		for _, e := range es {
+			i := int(b + e.index)
+			for len(check) <= i {
+				check = append(check, -1)
+				next = append(next, 0)
+			}
+			check[i], next[i] = e.index, e.value
+		}
+		base[v], shared[key], used[b] = b, b, true
+	}
+	return base, next, check
+}
+
+// lookup returns the entry of a packed vector or 0.
+func lookup(base int32, next, check []int32, index int) int32 {
+	if i := int(base) + index; i >= 0 && i < len(check) && check[i] == int32(index) {
+		return next[i]
+	}
+	return 0
+}
+
+// actionAt returns the action of a state for a terminal.
+func (t *tables) actionAt(st, term int) int32 {
+	if act := lookup(t.actBase[st], t.actNext, t.actCheck, term); act != 0 {
+		return act
+	}
+	return t.defaults[st]
+}
+
+// gotoAt returns the goto of a state over a non-terminal.
+func (t *tables) gotoAt(st, nt int) int32 {
+	return lookup(t.gotoBase[nt], t.gotoNext, t.gotoCheck, st)
+}
+
+// action returns the encoded action of a state for a token. The lookup doesn't allocate.
+// Identifiers that don't match a terminal literally are matched by the identifier terminal.
+func (a *automaton) action(st int, tok Token) int32 {
+	switch k := tok.Kind(); k {
+	case KindIdent:
+		if t, ok := a.matchIDs[tok.Text()]; ok {
+			if act := a.actionAt(st, int(t)); act != 0 {
+				return act
+			}
+		}
+		if a.identID >= 0 {
+			return a.actionAt(st, int(a.identID))
+		}
+	case KindKeyword, KindOther:
+		if t, ok := a.matchIDs[tok.Text()]; ok {
+			return a.actionAt(st, int(t))
+		}
+	case KindEOF:
+		return a.actionAt(st, int(a.eofID))
+	case KindInt, KindFloat, KindString, KindRawString, KindChar:
+		if t := a.litIDs[k]; t >= 0 {
+			return a.actionAt(st, int(t))
+		}
+	}
+	return 0
+}
+
+// gotoState returns the state reached from a state over the left-hand side of a rule or -1.
+func (a *automaton) gotoState(st, rule int) int {
+	return int(a.gotoAt(st, int(a.ruleLhs[rule]))) - 1
+}
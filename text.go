@@ -0,0 +1,161 @@
+package shred
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseRules parses rules written in the textual grammar format, in which rules are
+// written as they're printed:
+//
+//	# expressions
+//	0 -> E
+//	E -> E "+" T | T
+//	T -> _ident_
+//	   | "(" E ")"
+//
+// A rule takes one line. Alternatives are separated by '|' and a line starting with '|'
+// adds alternatives to the rule above. Matches are Go string literals, _ident_ and _eof_
+// are the identifier and EOF terminals, _int_, _float_, _string_, _rawstring_ and _char_
+// are literal terminals and other names are non-terminals. An alternative
+// may be empty. Comments start with '#' or "//" and extend to the end of the line.
+// Syntax errors are ParseErrors wrapping ErrGrammarSyntax.
+func ParseRules(src string) ([]*Rule, error) {
+	var rules []*Rule
+	lhs, offset := "", 0
+	for n, line := range strings.Split(src, "\n") {
+		l := &ruleLexer{line: line, pos: Position{offset, n + 1, 1}}
+		offset += len(line) + 1
+		l.skipSpace()
+		if l.done() {
+			continue
+		}
+		if l.peek() == '|' {
+			if lhs == "" {
+				return nil, l.errorf("alternative without a rule")
+			}
+		} else {
+			name, ok := l.name()
+			if !ok {
+				return nil, l.errorf("expected a non-terminal")
+			}
+			l.skipSpace()
+			if !strings.HasPrefix(l.line[l.i:], "->") {
+				return nil, l.errorf("expected '->' after %s", name)
+			}
+			l.advance(2)
+			lhs = name
+			rules = append(rules, &Rule{Lhs: lhs})
+		}
+		for {
+			l.skipSpace()
+			if l.done() {
+				break
+			}
+			if l.peek() == '|' {
+				l.advance(1)
+				rules = append(rules, &Rule{Lhs: lhs})
+				continue
+			}
+			sym, err := l.symbol()
+			if err != nil {
+				return nil, err
+			}
+			r := rules[len(rules)-1]
+			r.Rhs = append(r.Rhs, sym)
+		}
+	}
+	return rules, nil
+}
+
+// ruleLexer scans a line of the textual grammar format.
+type ruleLexer struct {
+	line string
+	i    int
+	pos  Position
+}
+
+func (l *ruleLexer) done() bool {
+	rest := l.line[l.i:]
+	return rest == "" || rest[0] == '#' || strings.HasPrefix(rest, "//")
+}
+
+func (l *ruleLexer) peek() rune {
+	c, _ := utf8.DecodeRuneInString(l.line[l.i:])
+	return c
+}
+
+// advance moves past n bytes.
+func (l *ruleLexer) advance(n int) {
+	l.pos = l.pos.advance(l.line[l.i : l.i+n])
+	l.i += n
+}
+
+func (l *ruleLexer) skipSpace() {
+	for l.i < len(l.line) && unicode.IsSpace(l.peek()) {
+		l.advance(utf8.RuneLen(l.peek()))
+	}
+}
+
+// name scans a name made of letters, digits and underscores.
+func (l *ruleLexer) name() (string, bool) {
+	start := l.i
+	for l.i < len(l.line) {
+		c := l.peek()
+		if c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			break
+		}
+		l.advance(utf8.RuneLen(c))
+	}
+	return l.line[start:l.i], l.i > start
+}
+
+// symbol scans a symbol of a right-hand side.
+func (l *ruleLexer) symbol() (Symbol, error) {
+	if l.peek() == '"' || l.peek() == '`' {
+		start, pos := l.i, l.pos
+		q := l.peek()
+		l.advance(1)
+		for !strings.HasPrefix(l.line[l.i:], string(q)) {
+			if l.i == len(l.line) {
+				l.pos = pos
+				return nil, l.errorf("unterminated match")
+			}
+			if q == '"' && l.peek() == '\\' && l.i+1 < len(l.line) {
+				l.advance(1)
+			}
+			l.advance(utf8.RuneLen(l.peek()))
+		}
+		l.advance(1)
+		text, err := strconv.Unquote(l.line[start:l.i])
+		if err != nil || text == "" {
+			l.pos = pos
+			return nil, l.errorf("invalid match %s", l.line[start:l.i])
+		}
+		return Match{text}, nil
+	}
+	name, ok := l.name()
+	if !ok {
+		return nil, l.errorf("unexpected %q", l.peek())
+	}
+	switch name {
+	case Ident{}.String():
+		return Ident{}, nil
+	case EOF{}.String():
+		return EOF{}, nil
+	}
+	for k := range literalNames {
+		if Literal(k).String() == name {
+			return Literal(k), nil
+		}
+	}
+	return NonTerminal{name}, nil
+}
+
+// errorf returns a syntax error at the current position.
+func (l *ruleLexer) errorf(format string, args ...interface{}) error {
+	return &ParseError{l.pos, newError(ErrGrammarSyntax, fmt.Sprintf(format, args...)), nil, nil}
+}
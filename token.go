@@ -1,10 +1,13 @@
 package shred
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
 	"text/scanner"
+	"time"
+	"unicode/utf8"
 )
 
 // Kind is a token's type.
@@ -20,67 +23,175 @@ const (
 	KindEOF
 	KindOther
 	KindMatch
+	KindKeyword
+	KindWhitespace
+	KindComment
+	KindPlaceholder
 )
 
+var kindNames = [...]string{"ident", "int", "float", "string", "rawstring", "char", "eof", "other", "match", "keyword", "whitespace", "comment", "placeholder"}
+
+// String returns the name of the kind.
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("kind(%d)", k)
+}
+
 // Token is a text token.
 type Token interface {
 	fmt.Stringer
 	Text() string
+	Raw() string
 	Kind() Kind
 	IsEOF() bool
 	IsIdent() bool
+	IsKeyword() bool
 	IsInt() bool
 	IsFloat() bool
 	IsString() bool
 	IsRawString() bool
 	IsChar() bool
+	IsWhitespace() bool
+	IsComment() bool
 	Line() int
 	Column() int
+	Pos() Position
+}
+
+// Position is a location in the source.
+type Position struct {
+	Offset int `json:"offset"` // byte offset, starting at 0
+	Line   int `json:"line"`   // line number, starting at 1
+	Column int `json:"column"` // column number in characters, starting at 1
+}
+
+// String returns the position in the form line:column.
+func (p Position) String() string { return fmt.Sprintf("%d:%d", p.Line, p.Column) }
+
+// advance returns the position after the given text.
+func (p Position) advance(text string) Position {
+	for _, c := range text {
+		if c == '\n' {
+			p.Line++
+			p.Column = 1
+		} else {
+			p.Column++
+		}
+	}
+	p.Offset += len(text)
+	return p
+}
+
+// End returns the position just after a token.
+func End(t Token) Position { return t.Pos().advance(t.Raw()) }
+
+// Span is a range in the source.
+type Span struct {
+	Start Position `json:"start"` // position of the first character
+	End   Position `json:"end"`   // position after the last character
+}
+
+// String returns the span in the form line:column-line:column.
+func (s Span) String() string { return s.Start.String() + "-" + s.End.String() }
+
+// tokenRange is a half-open range of token indices.
+type tokenRange struct {
+	first, last int
+}
+
+// spanOf returns the span of tokens[first:last]. An empty range spans nothing at the first token.
+func spanOf(tokens []Token, first, last int) Span {
+	if first == last {
+		p := tokens[first].Pos()
+		return Span{p, p}
+	}
+	return Span{tokens[first].Pos(), End(tokens[last-1])}
 }
 
 func isQuoted(t Token) bool { return t.IsString() || t.IsRawString() || t.IsChar() }
 
+func isQuotedKind(k Kind) bool { return k == KindString || k == KindRawString || k == KindChar }
+
+// isLiteralKind reports whether tokens of a kind are matched by literal terminals.
+func isLiteralKind(k Kind) bool { return k >= KindInt && k <= KindChar }
+
+// IsTrivia reports whether a token is whitespace or a comment.
+func IsTrivia(t Token) bool { return t.IsWhitespace() || t.IsComment() }
+
+// Scanner tokens for runs of whitespace and placeholders.
+const (
+	whitespace  = scanner.Comment - 1
+	placeholder = scanner.Comment - 2
+)
+
 type goToken struct {
-	tok  rune
-	text string
-	pos  scanner.Position
+	tok     rune
+	text    string
+	pos     scanner.Position
+	keyword bool
 }
 
 func (t *goToken) String() string {
-	return fmt.Sprintf("%s[%s:%d:%d]", scanner.TokenString(t.tok), t.Text(), t.Line(), t.Column())
+	name := scanner.TokenString(t.tok)
+	switch {
+	case t.keyword:
+		name = "Keyword"
+	case t.IsWhitespace():
+		name = "Whitespace"
+	case t.tok == placeholder:
+		name = "Placeholder"
+	}
+	return fmt.Sprintf("%s[%s:%d:%d]", name, t.Text(), t.Line(), t.Column())
 }
 
 func (t *goToken) Text() string {
-	if isQuoted(t) {
+	switch {
+	case isQuoted(t):
 		return t.text[1 : len(t.text)-1]
+	case t.tok == placeholder:
+		return t.text[1:]
 	}
 	return t.text
 }
 
+func (t *goToken) Raw() string { return t.text }
+
 func (t *goToken) Kind() Kind {
-	switch {
-	case t.IsIdent():
+	if t.keyword {
+		return KindKeyword
+	}
+	switch t.tok {
+	case scanner.Ident:
 		return KindIdent
-	case t.IsInt():
+	case scanner.Int:
 		return KindInt
-	case t.IsFloat():
+	case scanner.Float:
 		return KindFloat
-	case t.IsString():
+	case scanner.String:
 		return KindString
-	case t.IsRawString():
+	case scanner.RawString:
 		return KindRawString
-	case t.IsChar():
+	case scanner.Char:
 		return KindChar
-	case t.IsEOF():
+	case scanner.EOF:
 		return KindEOF
-	default:
-		return KindOther
+	case whitespace:
+		return KindWhitespace
+	case scanner.Comment:
+		return KindComment
+	case placeholder:
+		return KindPlaceholder
 	}
+	return KindOther
 }
 
 func (t *goToken) IsEOF() bool { return t.tok == scanner.EOF }
 
-func (t *goToken) IsIdent() bool { return t.tok == scanner.Ident }
+func (t *goToken) IsIdent() bool { return t.tok == scanner.Ident && !t.keyword }
+
+func (t *goToken) IsKeyword() bool { return t.keyword }
 
 func (t *goToken) IsInt() bool { return t.tok == scanner.Int }
 
@@ -92,10 +203,77 @@ func (t *goToken) IsRawString() bool { return t.tok == scanner.RawString }
 
 func (t *goToken) IsChar() bool { return t.tok == scanner.Char }
 
+func (t *goToken) IsWhitespace() bool { return t.tok == whitespace }
+
+func (t *goToken) IsComment() bool { return t.tok == scanner.Comment }
+
 func (t *goToken) Line() int { return t.pos.Line }
 
 func (t *goToken) Column() int { return t.pos.Column }
 
+func (t *goToken) Pos() Position { return Position{t.pos.Offset, t.pos.Line, t.pos.Column} }
+
+// kindTokens are the scanner tokens of the kinds of tokens made by NewToken.
+var kindTokens = [...]rune{
+	KindIdent:       scanner.Ident,
+	KindInt:         scanner.Int,
+	KindFloat:       scanner.Float,
+	KindString:      scanner.String,
+	KindRawString:   scanner.RawString,
+	KindChar:        scanner.Char,
+	KindEOF:         scanner.EOF,
+	KindKeyword:     scanner.Ident,
+	KindWhitespace:  whitespace,
+	KindComment:     scanner.Comment,
+	KindPlaceholder: placeholder,
+}
+
+// NewToken creates a token for lexers other than the tokenisers of the package, test fixtures
+// and passes rewriting token streams. The text is the token as written in the source, as returned
+// by Raw: strings, raw strings and chars include their quotes and placeholders the $. Tokens of
+// KindOther are usually operators and punctuation. NewToken panics if the kind is KindMatch,
+// which is a kind of terminals, or the text is too short for its kind.
+func NewToken(kind Kind, text string, pos Position) Token {
+	var tok rune
+	switch kind {
+	case KindOther:
+		tok, _ = utf8.DecodeRuneInString(text)
+	case KindMatch:
+		panic("shred: NewToken: no tokens of kind match")
+	default:
+		if int(kind) >= len(kindTokens) {
+			panic("shred: NewToken: unknown " + kind.String())
+		}
+		tok = kindTokens[kind]
+	}
+	switch {
+	case isQuotedKind(kind) && len(text) < 2, kind == KindPlaceholder && text == "":
+		panic(fmt.Sprintf("shred: NewToken: text %q too short for a token of kind %s", text, kind))
+	}
+	return &goToken{tok, text, scanner.Position{Offset: pos.Offset, Line: pos.Line, Column: pos.Column}, kind == KindKeyword}
+}
+
+// Tokeniser is a configurable tokeniser.
+// The zero value tokenises Go-like source without keywords.
+type Tokeniser struct {
+	// Keywords are identifiers that are tokenised as keywords.
+	Keywords []string
+	// KeepWhitespace makes the tokeniser emit whitespace runs and comments as tokens
+	// so that the source can be reconstructed from the token stream.
+	KeepWhitespace bool
+	// Placeholders makes the tokeniser emit $ followed by an identifier or digits, e.g. $x or $1,
+	// as placeholder tokens, whose text is the name without the $.
+	Placeholders bool
+	// NestedComments makes block comments nest, as in Rust or Haskell, so that a comment
+	// only ends at the */ matching its /* and /* /* */ */ is a single comment.
+	NestedComments bool
+	// Shebang makes the tokeniser treat a #! line at the beginning of the input, as in scripts
+	// executed directly, as a comment.
+	Shebang bool
+	// Hooks, if set, receive the durations of tokenisation.
+	Hooks Hooks
+}
+
 // TokeniseString tokenises a string.
 func TokeniseString(s string) []Token {
 	return Tokenise(strings.NewReader(s))
@@ -103,15 +281,74 @@ func TokeniseString(s string) []Token {
 
 // Tokenise tokenises the contents of a reader.
 func Tokenise(r io.Reader) []Token {
+	return new(Tokeniser).Tokenise(r)
+}
+
+// TokeniseContext tokenises the contents of a reader until the context is done.
+func TokeniseContext(ctx context.Context, r io.Reader) ([]Token, error) {
+	return new(Tokeniser).TokeniseContext(ctx, r)
+}
+
+// TokeniseString tokenises a string.
+func (tz *Tokeniser) TokeniseString(s string) []Token {
+	return tz.Tokenise(strings.NewReader(s))
+}
+
+// Tokenise tokenises the contents of a reader.
+func (tz *Tokeniser) Tokenise(r io.Reader) []Token {
+	if tz.Hooks != nil {
+		defer phaseDone(tz.Hooks, PhaseTokenise, time.Now())
+	}
 	var tokens []Token
-	var s scanner.Scanner
-	s.Init(r)
+	s := tz.NewScanner(r)
 	for {
-		tok := s.Scan()
-		tokens = append(tokens, &goToken{tok, s.TokenText(), s.Position})
-		if tok == scanner.EOF {
+		tok := s.Next()
+		tokens = append(tokens, tok)
+		if tok.IsEOF() {
 			break
 		}
 	}
 	return tokens
 }
+
+// checkInterval is the number of tokens scanned between context checks.
+const checkInterval = 1024
+
+// TokeniseContext tokenises the contents of a reader until the context is done.
+// The context is checked periodically and before every read.
+func (tz *Tokeniser) TokeniseContext(ctx context.Context, r io.Reader) ([]Token, error) {
+	if tz.Hooks != nil {
+		defer phaseDone(tz.Hooks, PhaseTokenise, time.Now())
+	}
+	var tokens []Token
+	s := tz.NewScanner(ctxReader{ctx, r})
+	for {
+		if len(tokens)%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		tok := s.Next()
+		tokens = append(tokens, tok)
+		if tok.IsEOF() {
+			break
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// ctxReader is a reader that ends when its context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, io.EOF
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,36 @@
+package shred
+
+import (
+	"fmt"
+	"io"
+)
+
+// Tracer receives the steps of a parse. States are identified by their numbers.
+type Tracer interface {
+	// Shift is called when a token is shifted moving from a state to the next one.
+	Shift(state int, tok Token, next int)
+	// Reduce is called when a rule is reduced in a state.
+	Reduce(state int, rule *Rule)
+	// Goto is called after a reduction when the parser moves from a state over a non-terminal.
+	Goto(state int, nt string, next int)
+}
+
+// LogTracer is a tracer that writes every step on a line.
+type LogTracer struct {
+	W io.Writer
+}
+
+// Shift logs a shift.
+func (t LogTracer) Shift(state int, tok Token, next int) {
+	fmt.Fprintf(t.W, "%d: shift %s -> %d\n", state, tok, next)
+}
+
+// Reduce logs a reduction.
+func (t LogTracer) Reduce(state int, rule *Rule) {
+	fmt.Fprintf(t.W, "%d: reduce %s\n", state, rule)
+}
+
+// Goto logs a goto.
+func (t LogTracer) Goto(state int, nt string, next int) {
+	fmt.Fprintf(t.W, "%d: goto %s -> %d\n", state, nt, next)
+}
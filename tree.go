@@ -0,0 +1,178 @@
+package shred
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node is a generic syntax tree node built for rules that have no builder.
+type Node struct {
+	rule     *Rule
+	children []interface{}
+	tokens   []Token
+	span     Span
+	doc      []Token
+}
+
+func newNode(r *Rule, args []interface{}, tokens []Token, first, last int) *Node {
+	return &Node{r, append([]interface{}(nil), args...), tokens[first:last], spanOf(tokens, first, last), nil}
+}
+
+// Rule returns the rule the node was built by.
+func (n *Node) Rule() *Rule { return n.rule }
+
+// Children returns the values of the rule's right-hand side symbols.
+func (n *Node) Children() []interface{} { return n.children }
+
+// Tokens returns the tokens covered by the node, including any trivia between them.
+func (n *Node) Tokens() []Token { return n.tokens }
+
+// Span returns the source range covered by the node.
+func (n *Node) Span() Span { return n.span }
+
+// Doc returns the doc comment attached to the node by CST.AttachDocs.
+func (n *Node) Doc() []Token { return n.doc }
+
+// Source returns the source text covered by the node.
+// It's exact if the tokens were produced with whitespace kept.
+func (n *Node) Source() string { return rawText(n.tokens) }
+
+// Walk calls fn for the node and its descendants in depth-first order.
+// If fn returns false, the children of the value it was called for are skipped.
+func (n *Node) Walk(fn func(interface{}) bool) { Inspect(n, fn) }
+
+// String returns the node in the form lhs(child ...) with tokens shown as in the source.
+func (n *Node) String() string {
+	var sb strings.Builder
+	sb.WriteString(n.rule.Lhs + "(")
+	for i, c := range n.children {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		switch c := c.(type) {
+		case Token:
+			sb.WriteString(c.Raw())
+		case fmt.Stringer:
+			sb.WriteString(c.String())
+		default:
+			fmt.Fprint(&sb, c)
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// ErrorNode covers tokens that couldn't be parsed.
+// The parser doesn't recover from errors yet, so error nodes are only made by NewErrorNode.
+type ErrorNode struct {
+	tokens []Token
+	span   Span
+	err    error
+}
+
+// NewErrorNode creates an error node covering a non-empty sequence of tokens.
+func NewErrorNode(tokens []Token, err error) *ErrorNode {
+	return &ErrorNode{tokens, spanOf(tokens, 0, len(tokens)), err}
+}
+
+// Tokens returns the skipped tokens.
+func (n *ErrorNode) Tokens() []Token { return n.tokens }
+
+// Span returns the source range of the skipped tokens.
+func (n *ErrorNode) Span() Span { return n.span }
+
+// Err returns the error that caused the tokens to be skipped.
+func (n *ErrorNode) Err() error { return n.err }
+
+// Source returns the source text of the skipped tokens.
+func (n *ErrorNode) Source() string { return rawText(n.tokens) }
+
+// String returns the node in the form error(token ...).
+func (n *ErrorNode) String() string {
+	ret := "error("
+	for i, t := range n.tokens {
+		if i > 0 {
+			ret += " "
+		}
+		ret += t.Raw()
+	}
+	return ret + ")"
+}
+
+func rawText(tokens []Token) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteString(t.Raw())
+	}
+	return sb.String()
+}
+
+// CST is a lossless concrete syntax tree.
+type CST struct {
+	root   *Node
+	tokens []Token
+}
+
+// ParseCST tokenises source keeping whitespace and comments and parses it into a tree of Nodes.
+// The rules' builders aren't called. If the tokeniser is nil, the default one is used.
+func (gr *Grammar) ParseCST(tz *Tokeniser, src string) (*CST, error) {
+	var t Tokeniser
+	if tz != nil {
+		t = *tz
+	}
+	t.KeepWhitespace = true
+	tokens := t.TokeniseString(src)
+	_, hooks := gr.current()
+	v, err := gr.parse(tokens, nodeBuilder{hooks}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CST{v.(*Node), tokens}, nil
+}
+
+// Root returns the root node.
+func (c *CST) Root() *Node { return c.root }
+
+// Tokens returns all tokens including whitespace and comments.
+func (c *CST) Tokens() []Token { return c.tokens }
+
+// Leading returns the tokens before the root node.
+func (c *CST) Leading() []Token { return c.tokens[:c.first()] }
+
+// Trailing returns the tokens after the root node, including the EOF token.
+func (c *CST) Trailing() []Token { return c.tokens[c.first()+len(c.root.tokens):] }
+
+func (c *CST) first() int {
+	if len(c.root.tokens) == 0 {
+		return 0
+	}
+	for i, t := range c.tokens {
+		if t == c.root.tokens[0] {
+			return i
+		}
+	}
+	return 0
+}
+
+// AttachDocs attaches doc comments to the nodes of the tree built by rules with the given
+// left-hand sides, e.g. the declarations of a language. Other nodes starting at the same
+// token, such as the lists of declarations, are passed over. See AttachDocs for what
+// the doc comments are.
+func (c *CST) AttachDocs(lhs ...string) {
+	takes := make(map[string]bool, len(lhs))
+	for _, l := range lhs {
+		takes[l] = true
+	}
+	attachDocs(c.root, c.tokens, func(v interface{}, doc []Token) bool {
+		if n, ok := v.(*Node); ok && takes[n.rule.Lhs] && len(n.tokens) > 0 {
+			n.doc = doc
+			return true
+		}
+		return false
+	})
+}
+
+// Source returns the source text the tree was parsed from.
+func (c *CST) Source() string {
+	return rawText(c.Leading()) + c.root.Source() + rawText(c.Trailing())
+}
@@ -0,0 +1,144 @@
+package shred
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tsReserved are the names of the rules that WriteTreeSitter adds to every grammar.
+var tsReserved = map[string]bool{
+	"source_file": true, "identifier": true, "comment": true,
+	"integer": true, "float": true, "string": true, "raw_string": true, "char": true,
+}
+
+// tsLiterals are the names and patterns of the rules of literal terminals.
+var tsLiterals = []struct {
+	kind          Kind
+	name, pattern string
+}{
+	{KindInt, "integer", `/\d+|0[xX][\da-fA-F]+/`},
+	{KindFloat, "float", `/\d+\.\d*([eE][+-]?\d+)?|\d+[eE][+-]?\d+/`},
+	{KindString, "string", `/"([^"\\\n]|\\.)*"/`},
+	{KindRawString, "raw_string", "/`[^`]*`/"},
+	{KindChar, "char", `/'([^'\\\n]|\\.)*'/`},
+}
+
+// tsName returns the tree-sitter name of a non-terminal before clashes are resolved.
+func tsName(nt string) string {
+	var sb strings.Builder
+	for i, c := range nt {
+		switch {
+		case c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c) && i > 0:
+			sb.WriteRune(c)
+		case unicode.IsDigit(c):
+			sb.WriteString("_")
+			sb.WriteRune(c)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	name := sb.String()
+	if tsReserved[name] {
+		name += "_"
+	}
+	return name
+}
+
+// WriteTreeSitter writes the rules of the grammar as a tree-sitter grammar.js so that a language
+// prototyped with shred can get editor support. Identifiers and comments are those of the default
+// tokeniser, as are literals, and EOF terminals are dropped. The grammar doesn't have to be built.
+//
+// tree-sitter is stricter than Build: rules that match the empty string and conflicts that shred
+// resolves by shifting must be handled in the exported file with precedences or a conflicts field.
+func (gr *Grammar) WriteTreeSitter(w io.Writer, name string) error {
+	var lhss []string
+	alts := make(map[string][]*Rule)
+	for _, r := range gr.Rules {
+		if _, ok := alts[r.Lhs]; !ok {
+			lhss = append(lhss, r.Lhs)
+		}
+		alts[r.Lhs] = append(alts[r.Lhs], r)
+	}
+	yn := &yaccNames{taken: make(map[string]bool), names: make(map[Symbol]string)}
+	yn.add(NonTerminal{"0"}, "source_file")
+	for name := range tsReserved {
+		yn.taken[name] = true
+	}
+	for _, lhs := range lhss {
+		yn.add(NonTerminal{lhs}, tsName(lhs))
+	}
+	for _, r := range gr.Rules {
+		for _, s := range r.Rhs {
+			if nt, ok := s.(NonTerminal); ok {
+				yn.add(nt, tsName(nt.Name))
+			}
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("// Generated by shred.\n\n")
+	fmt.Fprintf(&sb, "module.exports = grammar({\n  name: %s,\n\n", strconv.Quote(name))
+	sb.WriteString("  word: $ => $.identifier,\n\n  extras: $ => [/\\s/, $.comment],\n\n  rules: {\n")
+	if len(alts["0"]) > 0 {
+		lhss = append([]string{"0"}, lhss...)
+	}
+	done := make(map[string]bool)
+	for _, lhs := range lhss {
+		if done[lhs] {
+			continue
+		}
+		done[lhs] = true
+		var exprs []string
+		for _, r := range alts[lhs] {
+			exprs = append(exprs, tsSeq(r.Rhs, yn))
+		}
+		expr := exprs[0]
+		if len(exprs) > 1 {
+			expr = "choice(\n      " + strings.Join(exprs, ",\n      ") + ",\n    )"
+		}
+		fmt.Fprintf(&sb, "    %s: $ => %s,\n\n", yn.names[NonTerminal{lhs}], expr)
+	}
+	sb.WriteString("    identifier: $ => /[\\p{L}_][\\p{L}\\p{Nd}_]*/,\n\n")
+	for _, l := range tsLiterals {
+		for _, r := range gr.Rules {
+			if r.hasSymbol(Literal(l.kind)) {
+				fmt.Fprintf(&sb, "    %s: $ => %s,\n\n", l.name, l.pattern)
+				break
+			}
+		}
+	}
+	sb.WriteString("    comment: $ => token(choice(\n      seq('//', /.*/),\n      seq('/*', /[^*]*\\*+([^/*][^*]*\\*+)*/, '/'),\n    )),\n")
+	sb.WriteString("  },\n});\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// tsSeq returns the tree-sitter expression of a right-hand side with the names of the non-terminals.
+func tsSeq(rhs []Symbol, yn *yaccNames) string {
+	var syms []string
+	for _, s := range rhs {
+		switch s := s.(type) {
+		case NonTerminal:
+			syms = append(syms, "$."+yn.names[s])
+		case Match:
+			syms = append(syms, strconv.Quote(s.Text))
+		case Ident:
+			syms = append(syms, "$.identifier")
+		case Literal:
+			for _, l := range tsLiterals {
+				if l.kind == s.Kind() {
+					syms = append(syms, "$."+l.name)
+				}
+			}
+		}
+	}
+	switch len(syms) {
+	case 0:
+		return "blank()"
+	case 1:
+		return syms[0]
+	}
+	return "seq(" + strings.Join(syms, ", ") + ")"
+}
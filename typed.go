@@ -0,0 +1,38 @@
+package shred
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TypedRule is a rule whose builder produces values of a static type.
+type TypedRule[T any] struct {
+	Lhs     string
+	Rhs     []Symbol
+	Builder func(args []any) (T, error)
+}
+
+// Rule converts the typed rule to an untyped one that can be used in a grammar.
+func (r *TypedRule[T]) Rule() *Rule {
+	return &Rule{
+		Lhs: r.Lhs,
+		Rhs: r.Rhs,
+		BuilderErr: func(args []interface{}) (interface{}, error) {
+			return r.Builder(args)
+		},
+	}
+}
+
+// Parse parses a sequence of tokens and returns the result as a value of type T.
+func Parse[T any](gr *Grammar, tokens []Token) (T, error) {
+	var zero T
+	v, err := gr.Parse(tokens)
+	if err != nil {
+		return zero, err
+	}
+	ret, ok := v.(T)
+	if !ok {
+		return zero, newError(ErrResultType, fmt.Sprintf("parse result of type %T is not %s", v, reflect.TypeOf(&zero).Elem()))
+	}
+	return ret, nil
+}
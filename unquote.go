@@ -0,0 +1,145 @@
+package shred
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Escapes are the escape sequences of the string and char literals of a language.
+// Simple maps the characters following a backslash to their replacements, e.g. 'n' to "\n".
+// Hex enables \x followed by two hex digits and Octal a backslash followed by three octal digits,
+// both standing for a byte. Unicode enables \u followed by four hex digits and \U followed by eight,
+// both standing for a code point; a pair of \u escapes of UTF-16 surrogates stands for the code point
+// they encode.
+type Escapes struct {
+	Simple  map[byte]string
+	Hex     bool
+	Octal   bool
+	Unicode bool
+}
+
+// GoEscapes are the escape sequences of Go.
+var GoEscapes = &Escapes{
+	Simple: map[byte]string{
+		'a': "\a", 'b': "\b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t", 'v': "\v",
+		'\\': "\\", '\'': "'", '"': "\"",
+	},
+	Hex:     true,
+	Octal:   true,
+	Unicode: true,
+}
+
+// JSONEscapes are the escape sequences of JSON.
+var JSONEscapes = &Escapes{
+	Simple: map[byte]string{
+		'b': "\b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t", '\\': "\\", '/': "/", '"': "\"",
+	},
+	Unicode: true,
+}
+
+// Unquote returns the text of a string or char token with the escape sequences of Go interpreted.
+// It's Unquote of GoEscapes.
+func Unquote(tok Token) (string, error) { return GoEscapes.Unquote(tok) }
+
+// Unquote returns the text of a string or char token with its escape sequences interpreted.
+// The text of raw strings is returned as it is. An invalid escape sequence is reported
+// in a ParseError at its position wrapping ErrEscape, other tokens are errors wrapping ErrResultType.
+func (e *Escapes) Unquote(tok Token) (string, error) {
+	switch {
+	case tok.IsRawString():
+		return tok.Text(), nil
+	case !tok.IsString() && !tok.IsChar():
+		return "", newError(ErrResultType, "expected a string, got "+describeToken(tok))
+	}
+	s := tok.Text()
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			i++
+			continue
+		}
+		n, ok := e.escape(&sb, s[i+1:])
+		if !ok {
+			pos := tok.Pos().advance(tok.Raw()[:1+i])
+			return "", &ParseError{Pos: pos, Err: newError(ErrEscape, "invalid escape sequence "+s[i:i+1+n]), Token: tok}
+		}
+		i += 1 + n
+	}
+	return sb.String(), nil
+}
+
+// escape writes the replacement of the escape sequence at the beginning of s, which follows
+// a backslash, and returns its length. If it's invalid, it returns false and the length
+// of the sequence to report.
+func (e *Escapes) escape(sb *strings.Builder, s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if r, ok := e.Simple[s[0]]; ok {
+		sb.WriteString(r)
+		return 1, true
+	}
+	switch c := s[0]; {
+	case c == 'x' && e.Hex:
+		n, ok := digits(s[1:], 2, 16)
+		if !ok {
+			return clip(s, 3), false
+		}
+		sb.WriteByte(byte(n))
+		return 3, true
+	case '0' <= c && c <= '7' && e.Octal:
+		n, ok := digits(s, 3, 8)
+		if !ok || n > 255 {
+			return clip(s, 3), false
+		}
+		sb.WriteByte(byte(n))
+		return 3, true
+	case (c == 'u' || c == 'U') && e.Unicode:
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		n, ok := digits(s[1:], size, 16)
+		if !ok {
+			return clip(s, 1+size), false
+		}
+		r, l := rune(n), 1+size
+		if utf16.IsSurrogate(r) && c == 'u' && strings.HasPrefix(s[l:], `\u`) {
+			if n2, ok := digits(s[l+2:], 4, 16); ok {
+				if r2 := utf16.DecodeRune(r, rune(n2)); r2 != utf8.RuneError {
+					r, l = r2, l+6
+				}
+			}
+		}
+		if !utf8.ValidRune(r) {
+			return l, false
+		}
+		sb.WriteRune(r)
+		return l, true
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return size, false
+}
+
+// clip returns n or the length of s if it's shorter.
+func clip(s string, n int) int {
+	if len(s) < n {
+		return len(s)
+	}
+	return n
+}
+
+// digits parses a number of the given count of digits in a base at the beginning of s.
+func digits(s string, count, base int) (uint64, bool) {
+	if len(s) < count {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[:count], base, 32)
+	return n, err == nil
+}
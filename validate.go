@@ -0,0 +1,125 @@
+package shred
+
+import "fmt"
+
+// Problem is an error or a warning about a grammar found by Validate.
+type Problem struct {
+	Severity Severity
+	Code     Code
+	Rule     *Rule // rule the problem is about or nil
+	Msg      string
+}
+
+// String returns the severity and message of the problem followed by its code.
+func (p Problem) String() string {
+	return p.Severity.String() + ": " + p.Msg + " [" + string(p.Code) + "]"
+}
+
+// Report is the result of Validate. Errors come before warnings.
+type Report struct {
+	Problems []Problem
+}
+
+// HasErrors reports whether there are problems with the severity error.
+func (r *Report) HasErrors() bool {
+	for _, p := range r.Problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate performs the static checks of the rules without building the automaton, so it's fast
+// enough to run on every edit of a grammar. Errors are nil rules and symbols, literal terminals
+// of kinds without literals, a missing rule for the start symbol and undefined non-terminals.
+// Warnings are rules unreachable from the start symbol, non-terminals that derive no string
+// of terminals and the warnings of Check that don't need the automaton. Conflicts are only
+// found by Build.
+func (gr *Grammar) Validate() *Report {
+	var errs, warns []Problem
+	errorf := func(code Code, r *Rule, format string, args ...interface{}) {
+		errs = append(errs, Problem{SeverityError, code, r, fmt.Sprintf(format, args...)})
+	}
+	warnf := func(code Code, r *Rule, format string, args ...interface{}) {
+		warns = append(warns, Problem{SeverityWarning, code, r, fmt.Sprintf(format, args...)})
+	}
+	var rules []*Rule
+	lhss := make(map[string][]*Rule)
+	for i, r := range gr.Rules {
+		if r == nil {
+			errorf(CodeNil, nil, "rule %d is nil", i)
+			continue
+		}
+		ok := true
+		for j, s := range r.Rhs {
+			if s == nil {
+				errorf(CodeNil, r, "symbol %d of rule %d is nil", j, i)
+				ok = false
+			} else if l, isLit := s.(Literal); isLit && !isLiteralKind(Kind(l)) {
+				errorf(CodeNotLiteral, r, "%s in rule %s is not a literal terminal", l, r)
+			}
+		}
+		if ok {
+			rules = append(rules, r)
+			lhss[r.Lhs] = append(lhss[r.Lhs], r)
+		}
+	}
+	if len(lhss["0"]) == 0 {
+		errorf(CodeNoStart, nil, "no rule for the start symbol 0")
+	}
+	undefined := make(map[string]bool)
+	for _, r := range rules {
+		for _, s := range r.Rhs {
+			if nt, ok := s.(NonTerminal); ok && len(lhss[nt.Name]) == 0 && !undefined[nt.Name] {
+				undefined[nt.Name] = true
+				errorf(CodeUndefined, r, "non-terminal %s in rule %s has no rules", nt.Name, r)
+			}
+		}
+	}
+	reachable := map[string]bool{"0": true}
+	for queue := []string{"0"}; len(queue) > 0; queue = queue[1:] {
+		for _, r := range lhss[queue[0]] {
+			for _, s := range r.Rhs {
+				if nt, ok := s.(NonTerminal); ok && !reachable[nt.Name] {
+					reachable[nt.Name] = true
+					queue = append(queue, nt.Name)
+				}
+			}
+		}
+	}
+	productive := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, r := range rules {
+			if !productive[r.Lhs] && derives(r, productive) {
+				productive[r.Lhs] = true
+				changed = true
+			}
+		}
+	}
+	reported := make(map[string]bool)
+	for _, r := range rules {
+		switch {
+		case !reachable[r.Lhs]:
+			warnf(CodeUnreachable, r, "rule %s is unreachable from the start symbol", r)
+		case !productive[r.Lhs] && !reported[r.Lhs]:
+			reported[r.Lhs] = true
+			warnf(CodeUnproductive, r, "non-terminal %s derives no string of terminals", r.Lhs)
+		}
+	}
+	for _, w := range gr.ruleWarnings(rules) {
+		warns = append(warns, Problem{SeverityWarning, w.Code, w.Rule, w.Msg})
+	}
+	return &Report{append(errs, warns...)}
+}
+
+// derives reports whether all non-terminals on the right-hand side of a rule are productive.
+func derives(r *Rule, productive map[string]bool) bool {
+	for _, s := range r.Rhs {
+		if nt, ok := s.(NonTerminal); ok && !productive[nt.Name] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package shred
+
+import "reflect"
+
+// WalkAction tells a walk how to proceed after visiting a value.
+type WalkAction byte
+
+const (
+	WalkContinue WalkAction = iota // continue with the children
+	WalkSkip                       // skip the children (ignored after them)
+	WalkStop                       // stop the walk
+)
+
+// Visitor is called for every value of a parse result.
+type Visitor interface {
+	// Enter is called before the children of a value are visited.
+	Enter(v interface{}) WalkAction
+	// Leave is called after the children of a value have been visited.
+	Leave(v interface{}) WalkAction
+}
+
+// VisitorFuncs is a visitor made of callbacks. Nil callbacks continue the walk.
+type VisitorFuncs struct {
+	Pre  func(v interface{}) WalkAction
+	Post func(v interface{}) WalkAction
+}
+
+// Enter calls the Pre callback.
+func (f VisitorFuncs) Enter(v interface{}) WalkAction {
+	if f.Pre == nil {
+		return WalkContinue
+	}
+	return f.Pre(v)
+}
+
+// Leave calls the Post callback.
+func (f VisitorFuncs) Leave(v interface{}) WalkAction {
+	if f.Post == nil {
+		return WalkContinue
+	}
+	return f.Post(v)
+}
+
+// Parent is a value with children, such as a Node.
+// User AST types can implement it to choose their children.
+type Parent interface {
+	Children() []interface{}
+}
+
+// Children returns the children of a value of a parse result.
+// Parents and slices of values have children, and so do other user AST values, which are
+// walked by reflection: the children of pointers to structs, structs, slices and arrays are
+// the values in their exported fields and elements that are pointers to structs or are held
+// by interfaces, the elements of slices and arrays and the fields of structs found in them,
+// including embedded ones, taking their places. Nil values, maps and fields tagged
+// shred:"-", e.g. back references that would make walks cycle, are left out.
+// Tokens and the declarations and scopes of Scopes have no children.
+func Children(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil, Token, *Decl, *Scope:
+		return nil
+	case Parent:
+		return v.Children()
+	case []interface{}:
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	return appendChildren(nil, rv)
+}
+
+// appendChildren appends the children of a struct, slice or array.
+func appendChildren(ret []interface{}, rv reflect.Value) []interface{} {
+	switch rv.Kind() {
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < rv.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() && f.Tag.Get("shred") != "-" {
+				ret = appendChild(ret, rv.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			ret = appendChild(ret, rv.Index(i))
+		}
+	}
+	return ret
+}
+
+// appendChild appends a field or an element if it's a child or the children in it.
+func appendChild(ret []interface{}, rv reflect.Value) []interface{} {
+	switch rv.Kind() {
+	case reflect.Interface:
+		if !rv.IsNil() {
+			ret = append(ret, rv.Interface())
+		}
+	case reflect.Ptr:
+		if !rv.IsNil() && rv.Elem().Kind() == reflect.Struct {
+			ret = append(ret, rv.Interface())
+		}
+	case reflect.Struct, reflect.Slice, reflect.Array:
+		ret = appendChildren(ret, rv)
+	}
+	return ret
+}
+
+// Visit walks a parse result depth-first. It reports whether the walk completed without being stopped.
+func Visit(root interface{}, v Visitor) bool {
+	switch v.Enter(root) {
+	case WalkStop:
+		return false
+	case WalkContinue:
+		for _, c := range Children(root) {
+			if !Visit(c, v) {
+				return false
+			}
+		}
+	}
+	return v.Leave(root) != WalkStop
+}
+
+// Inspect walks a parse result depth-first calling fn before visiting the children of each value,
+// which are those of Children, so it walks user ASTs without walk functions of their own.
+// The children are skipped if fn returns false.
+func Inspect(root interface{}, fn func(interface{}) bool) {
+	Visit(root, VisitorFuncs{Pre: func(v interface{}) WalkAction {
+		if fn(v) {
+			return WalkContinue
+		}
+		return WalkSkip
+	}})
+}
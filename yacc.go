@@ -0,0 +1,149 @@
+package shred
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// yaccNames names the symbols of an exported yacc grammar. Non-terminals keep their names,
+// identifier-like matches are upper-cased, one-character ones are character literals and
+// other ones are numbered. Clashing names get a suffix.
+type yaccNames struct {
+	taken map[string]bool
+	names map[Symbol]string
+}
+
+func (yn *yaccNames) add(s Symbol, name string) {
+	if _, ok := yn.names[s]; ok {
+		return
+	}
+	for n := 1; yn.taken[name]; n++ {
+		name = strings.TrimRight(name, "_0123456789") + "_" + strconv.Itoa(n)
+	}
+	yn.taken[name] = true
+	yn.names[s] = name
+}
+
+// sanitise replaces characters that can't appear in yacc names.
+func sanitise(name string) string {
+	var sb strings.Builder
+	for i, c := range name {
+		switch {
+		case c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c) && i > 0:
+			sb.WriteRune(c)
+		case unicode.IsDigit(c):
+			sb.WriteString("_")
+			sb.WriteRune(c)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// WriteYacc writes the rules of the grammar as a goyacc grammar of the given package so that
+// grammars can be migrated between the two generators and their tables compared. Matches are
+// declared as tokens, the identifier terminal as IDENT and literals as INT, STRING and so on,
+// all of type val; EOF terminals are
+// dropped with their values. The start symbol is named start. Every rule has an action calling
+// a function named like the rule's builder in the Builders struct of WriteGo with the values of
+// its right-hand side, which has to be defined. The grammar doesn't have to be built.
+func (gr *Grammar) WriteYacc(w io.Writer, pkg string) error {
+	yn := &yaccNames{taken: map[string]bool{"error": true}, names: make(map[Symbol]string)}
+	var lhss []string
+	alts := make(map[string][]int) // numbers of the rules by left-hand side
+	for i, r := range gr.Rules {
+		if _, ok := alts[r.Lhs]; !ok {
+			lhss = append(lhss, r.Lhs)
+		}
+		alts[r.Lhs] = append(alts[r.Lhs], i)
+	}
+	if len(alts["0"]) > 0 {
+		yn.add(NonTerminal{"0"}, "start")
+	}
+	for _, lhs := range lhss {
+		yn.add(NonTerminal{lhs}, sanitise(lhs))
+	}
+	var tokens []string
+	for _, r := range gr.Rules {
+		for _, s := range r.Rhs {
+			if _, ok := yn.names[s]; ok {
+				continue
+			}
+			switch s := s.(type) {
+			case NonTerminal:
+				yn.add(s, sanitise(s.Name))
+			case Ident:
+				yn.add(s, "IDENT")
+			case Literal:
+				yn.add(s, strings.ToUpper(s.Kind().String()))
+			case Match:
+				c, n := utf8.DecodeRuneInString(s.Text)
+				switch {
+				case n == len(s.Text) && (unicode.IsPunct(c) || unicode.IsSymbol(c)):
+					yn.add(s, strconv.QuoteRune(c))
+				case sanitise(s.Text) == s.Text:
+					yn.add(s, strings.ToUpper(s.Text))
+				default:
+					yn.add(s, "TOKEN_"+strconv.Itoa(len(tokens)+1))
+				}
+			default:
+				continue
+			}
+			if t, ok := s.(Terminal); ok {
+				tokens = append(tokens, fmt.Sprintf("%%token <val> %s /* %s */\n", yn.names[s], t))
+			}
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("/* Generated by shred. Define the functions called by the actions. */\n\n")
+	fmt.Fprintf(&sb, "%%{\npackage %s\n%%}\n\n%%union {\n\tval interface{}\n}\n\n", pkg)
+	for _, t := range tokens {
+		sb.WriteString(t)
+	}
+	sb.WriteString("\n%type <val>")
+	if len(alts["0"]) > 0 {
+		lhss = append([]string{"0"}, lhss...)
+	}
+	done := make(map[string]bool)
+	var order []string
+	for _, lhs := range lhss {
+		if !done[lhs] {
+			done[lhs] = true
+			order = append(order, lhs)
+			sb.WriteString(" " + yn.names[NonTerminal{lhs}])
+		}
+	}
+	if len(order) > 0 {
+		fmt.Fprintf(&sb, "\n\n%%start %s", yn.names[NonTerminal{order[0]}])
+	}
+	sb.WriteString("\n\n%%\n")
+	builders := builderNames(gr.Rules)
+	for _, lhs := range order {
+		fmt.Fprintf(&sb, "\n%s:\n", yn.names[NonTerminal{lhs}])
+		for i, ri := range alts[lhs] {
+			r := gr.Rules[ri]
+			if i > 0 {
+				sb.WriteString("|")
+			}
+			var syms, args []string
+			for _, s := range r.Rhs {
+				if name, ok := yn.names[s]; ok {
+					syms = append(syms, name)
+					args = append(args, "$"+strconv.Itoa(len(syms)))
+				}
+			}
+			if len(syms) == 0 {
+				syms = []string{"/* empty */"}
+			}
+			fmt.Fprintf(&sb, "\t%s\n\t{ $$ = %s(%s) }\n", strings.Join(syms, " "), builders[ri], strings.Join(args, ", "))
+		}
+		sb.WriteString(";\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}